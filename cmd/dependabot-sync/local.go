@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/local"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/merger"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/util"
+	"github.com/google/go-github/v50/github"
+)
+
+// runLocal detects ecosystems in a local checkout and writes the merged
+// configuration back to disk, or prints a diff in dry-run mode
+func runLocal(ctx context.Context, opts *options, mrg *merger.Merger) error {
+	root, err := filepath.Abs(opts.localPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve local checkout path: %w", err)
+	}
+	checkout := local.New(root)
+	name := filepath.Base(root)
+
+	fmt.Printf("🔄 Checking Dependabot configuration for local checkout: %s\n", checkout.Root())
+
+	// Detect ecosystems
+	ecosystems, err := detector.New(checkout).Detect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to detect ecosystems: %w", err)
+	}
+
+	if len(ecosystems) == 0 {
+		fmt.Printf("⏭️  %s: no supported ecosystems detected\n", name)
+		return nil
+	}
+
+	// Get existing configuration
+	existingConfig, existingContent, path, err := checkout.ReadExistingConfig()
+	if err != nil {
+		return err
+	}
+
+	target := &merger.Target{Repo: &github.Repository{Name: github.String(name)}}
+	if mrg.UsesTeam() {
+		if target.Team, err = checkout.CodeOwnersTeam(); err != nil {
+			return err
+		}
+	}
+
+	// Merge configurations
+	mergedConfig := mrg.Merge(existingConfig, ecosystems, target)
+	var pruned []config.DependabotUpdate
+	if opts.prune && existingConfig != nil {
+		pruned = merger.Prune(mergedConfig, ecosystems, existingContent)
+	}
+	if opts.collapseDirs {
+		merger.Collapse(mergedConfig, ecosystems)
+	}
+
+	content, changed, err := merger.Render(existingContent, mergedConfig, opts.yamlIndent)
+	if err != nil {
+		return err
+	}
+
+	if existingConfig != nil && (existingConfig.Equal(mergedConfig) || !changed) {
+		fmt.Printf("✅ %s: already configured\n", name)
+		return nil
+	}
+
+	for _, update := range pruned {
+		fmt.Printf("🗑️  %s: pruned %s\n", name, describeUpdate(update))
+	}
+
+	if opts.dryRun {
+		fmt.Print(formatDiff(util.UnifiedDiff("a/"+path, "b/"+path, existingContent, content)))
+		return nil
+	}
+
+	if err := checkout.WriteFile(path, content); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ %s: %s updated\n", name, path)
+	return nil
+}
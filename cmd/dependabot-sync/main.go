@@ -2,13 +2,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/google/go-github/v50/github"
 	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
@@ -16,27 +23,48 @@ import (
 	githubClient "github.com/enthus-appdev/dependabot-config-manager/internal/github"
 	"github.com/enthus-appdev/dependabot-config-manager/internal/merger"
 	"github.com/enthus-appdev/dependabot-config-manager/internal/reporter"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/state"
 	"github.com/enthus-appdev/dependabot-config-manager/internal/util"
 )
 
+// maxConflictRetries is how often a repository is merged again when its
+// configuration changes while being synced
+const maxConflictRetries = 3
+
 // Version is the application version
 var Version = "1.0.0"
 
 type options struct {
-	token           string
-	org             string
-	dryRun          bool
-	createPR        bool
-	repositories    []string
-	excludeArchived bool
-	excludeTopics   []string
-	configDir       string
-	reportDir       string
-	reportFormat    string
-	concurrency     int
-	verbose         bool
-	version         bool
-	yamlIndent      int
+	token             string
+	appID             int64
+	appPrivateKey     string
+	appInstallationID int64
+	baseURL           string
+	uploadURL         string
+	caBundle          string
+	cacheDir          string
+	org               string
+	dryRun            bool
+	createPR          bool
+	repositories      []string
+	excludeArchived   bool
+	excludeTopics     []string
+	configDir         string
+	reportDir         string
+	reportFormat      string
+	concurrency       int
+	verbose           bool
+	version           bool
+	yamlIndent        int
+	localPath         string
+	graphQL           bool
+	stateFile         string
+	full              bool
+	checkpointFile    string
+	checkpointEvery   int
+	resume            bool
+	prune             bool
+	collapseDirs      bool
 }
 
 func main() {
@@ -51,13 +79,13 @@ func main() {
 		log.Fatalf("❌ Invalid options: %v", err)
 	}
 
-	ctx := context.Background()
-
-	// Create GitHub client
-	client := githubClient.NewClient(opts.token, opts.org)
-
-	// Create detector
-	det := detector.New(client.GetClient(), opts.org)
+	// Stop cleanly on Ctrl-C or when CI cancels the job; a second signal
+	// kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
 	// Create merger
 	mrg, err := merger.New(opts.configDir)
@@ -65,24 +93,88 @@ func main() {
 		log.Fatalf("❌ Failed to initialize merger: %v", err)
 	}
 
+	// Local checkout mode works on disk and never talks to GitHub
+	if opts.localPath != "" {
+		if err := runLocal(ctx, opts, mrg); err != nil {
+			log.Fatalf("❌ Local sync failed: %v", err)
+		}
+		return
+	}
+
+	// Create GitHub client
+	client, err := newGitHubClient(opts)
+	if err != nil {
+		log.Fatalf("❌ Failed to create GitHub client: %v", err)
+	}
+
+	// Log when requests are held back by the rate limit
+	limiter := client.RateLimiter()
+	limiter.OnWait = func(wait time.Duration, reason string) {
+		log.Printf("⏳ %s, waiting %s", reason, wait.Round(time.Second))
+	}
+
+	// Create detector
+	det := detector.New(client)
+
 	// Create reporter
 	rep := reporter.New(opts.org, opts.reportDir, opts.verbose)
 
 	// Create synchronizer
 	syncer := &Synchronizer{
-		client:    client,
-		detector:  det,
-		merger:    mrg,
-		reporter:  rep,
-		options:   opts,
-		semaphore: make(chan struct{}, opts.concurrency),
-		wg:        &sync.WaitGroup{},
+		client:   client,
+		detector: det,
+		merger:   mrg,
+		reporter: rep,
+		options:  opts,
+		limiter:  limiter,
+		wg:       &sync.WaitGroup{},
+	}
+	syncer.semaphore = util.NewAdaptiveSemaphore(syncer.concurrency)
+
+	// Load what earlier runs saw to skip unchanged repositories
+	if opts.stateFile != "" {
+		syncer.state, err = state.Load(opts.stateFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to load state: %v", err)
+		}
+		syncer.templateHash = mrg.Fingerprint()
+		// Pruning and collapsing change the result for the same templates
+		if opts.prune {
+			syncer.templateHash += "+prune"
+		}
+		if opts.collapseDirs {
+			syncer.templateHash += "+collapse"
+		}
+	}
+
+	// Remember finished repositories in case the run is interrupted
+	checkpointPath := opts.checkpointFile
+	if checkpointPath == "" {
+		checkpointPath = filepath.Join(opts.reportDir, "checkpoint.json")
+	}
+	syncer.checkpoint = state.NewCheckpoint(checkpointPath, opts.org)
+	if opts.resume {
+		syncer.checkpoint, err = state.LoadCheckpoint(checkpointPath, opts.org)
+		if err != nil {
+			log.Fatalf("❌ Failed to resume: %v", err)
+		}
 	}
 
 	// Run synchronization
-	if err := syncer.Run(ctx); err != nil {
+	err = syncer.Run(ctx)
+	interrupted := errors.Is(err, context.Canceled)
+	if err != nil && !interrupted {
 		log.Fatalf("❌ Synchronization failed: %v", err)
 	}
+	if interrupted {
+		log.Printf("🛑 %v; continue with -resume", err)
+	}
+
+	if syncer.state != nil {
+		if err := syncer.state.Save(); err != nil {
+			log.Printf("⚠️  Failed to save state: %v", err)
+		}
+	}
 
 	// Save report
 	if err := rep.SaveReport(opts.reportFormat); err != nil {
@@ -91,17 +183,40 @@ func main() {
 
 	// Print summary
 	rep.PrintSummary()
+
+	if cache := client.Cache(); cache != nil {
+		hits, misses := cache.Stats()
+		fmt.Printf("💾 HTTP cache: %d responses unchanged, %d downloaded\n", hits, misses)
+	}
+
+	if interrupted {
+		os.Exit(130)
+	}
 }
 
 // Synchronizer orchestrates the synchronization process
 type Synchronizer struct {
-	client    *githubClient.Client
+	client    githubClient.API
 	detector  *detector.Detector
 	merger    *merger.Merger
 	reporter  *reporter.Reporter
 	options   *options
-	semaphore chan struct{}
+	limiter   *githubClient.RateLimiter
+	semaphore *util.Semaphore
 	wg        *sync.WaitGroup
+
+	// snapshots holds what GraphQL prefetched per repository; it is filled
+	// before repositories are processed and read-only afterwards
+	snapshots map[string]*githubClient.RepositorySnapshot
+
+	// state, if set, records each repository so later runs can skip it
+	// while its head commit and templateKey stay the same
+	state        *state.Store
+	templateHash string
+
+	// checkpoint, if set, records finished repositories; repositories it
+	// already lists are not processed again
+	checkpoint *state.Checkpoint
 }
 
 // Run executes the synchronization process
@@ -112,12 +227,26 @@ func (s *Synchronizer) Run(ctx context.Context) error {
 		fmt.Println("🔍 Running in DRY-RUN mode - no changes will be made")
 	}
 
-	// Get repositories
-	repos, err := s.getRepositories(ctx)
+	// Get repositories, counting the calls that belong to no single repository
+	listCtx, calls := githubClient.WithCallCounter(ctx)
+	repos, err := s.getRepositories(listCtx)
+	s.reporter.AddAPICalls(calls.Count())
 	if err != nil {
 		return fmt.Errorf("failed to get repositories: %w", err)
 	}
 
+	// Leave out what an interrupted run already finished
+	if s.checkpoint != nil && s.checkpoint.Len() > 0 {
+		var remaining []*github.Repository
+		for _, repo := range repos {
+			if !s.checkpoint.IsDone(repo.GetName()) {
+				remaining = append(remaining, repo)
+			}
+		}
+		fmt.Printf("⏩ Resuming: %d repositories already done\n", len(repos)-len(remaining))
+		repos = remaining
+	}
+
 	fmt.Printf("📚 Found %d repositories to process\n", len(repos))
 
 	// Process repositories concurrently
@@ -129,15 +258,38 @@ func (s *Synchronizer) Run(ctx context.Context) error {
 	// Wait for all processing to complete
 	s.wg.Wait()
 
+	if s.checkpoint == nil {
+		return ctx.Err()
+	}
+	if ctx.Err() != nil {
+		if err := s.checkpoint.Save(); err != nil {
+			log.Printf("⚠️  Failed to save checkpoint: %v", err)
+		}
+		return fmt.Errorf("interrupted with %d repositories done: %w", s.checkpoint.Len(), ctx.Err())
+	}
+	// The run is complete either way; a leftover checkpoint only matters
+	// to a later --resume
+	if err := s.checkpoint.Remove(); err != nil {
+		log.Printf("⚠️  Failed to remove checkpoint: %v", err)
+	}
 	return nil
 }
 
-// getRepositories gets the list of repositories to process
+// getRepositories gets the list of repositories to process, prefetching
+// their snapshots when GraphQL is enabled
 func (s *Synchronizer) getRepositories(ctx context.Context) ([]*github.Repository, error) {
+	s.snapshots = nil
+
 	if len(s.options.repositories) > 0 {
+		s.prefetch(ctx, s.options.repositories)
+
 		// Get specific repositories
 		var repos []*github.Repository
 		for _, name := range s.options.repositories {
+			if snapshot, ok := s.snapshots[name]; ok {
+				repos = append(repos, snapshot.Repository())
+				continue
+			}
 			repo, err := s.client.GetRepository(ctx, name)
 			if err != nil {
 				log.Printf("⚠️  Failed to get repository %s: %v", name, err)
@@ -149,119 +301,407 @@ func (s *Synchronizer) getRepositories(ctx context.Context) ([]*github.Repositor
 	}
 
 	// Get all organization repositories
-	return s.client.ListRepositories(ctx, s.options.excludeArchived)
+	repos, err := s.client.ListRepositories(ctx, s.options.excludeArchived)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		names = append(names, repo.GetName())
+	}
+	s.prefetch(ctx, names)
+
+	return repos, nil
+}
+
+// prefetch fetches snapshots of the named repositories through GraphQL, if
+// enabled, so processing them needs fewer REST calls. On failure the REST
+// API is used for everything.
+func (s *Synchronizer) prefetch(ctx context.Context, names []string) {
+	if !s.options.graphQL {
+		return
+	}
+
+	snapshots, err := s.client.GetSnapshots(ctx, names)
+	if err != nil {
+		log.Printf("⚠️  Failed to fetch repositories through GraphQL, using the REST API: %v", err)
+		return
+	}
+	s.snapshots = snapshots
+}
+
+// concurrency returns how many repositories may be processed at once, fewer
+// than configured while GitHub asks us to slow down
+func (s *Synchronizer) concurrency() int {
+	return s.limiter.Concurrency(s.options.concurrency)
 }
 
 // processRepository processes a single repository
 func (s *Synchronizer) processRepository(ctx context.Context, repo *github.Repository) {
 	defer s.wg.Done()
 
-	// Acquire semaphore
-	s.semaphore <- struct{}{}
-	defer func() { <-s.semaphore }()
+	// Acquire semaphore, narrowed while GitHub asks us to slow down
+	if err := s.semaphore.Acquire(ctx); err != nil {
+		return
+	}
+	defer s.semaphore.Release()
 
 	repoName := repo.GetName()
 
+	// Time the repository and count the API calls made for it
+	ctx, calls := githubClient.WithCallCounter(ctx)
+	run := s.reporter.StartRepository(repo, calls.Count)
+
 	if s.options.verbose {
 		fmt.Printf("🔍 Processing repository: %s\n", repoName)
 	}
 
 	// Check exclusion topics
 	if s.detector.HasExclusionTopic(ctx, repo) {
-		s.reporter.AddSkippedRepository(repo, "has exclusion topic")
+		s.finished(repoName)
+		run.Skipped("has exclusion topic")
 		if s.options.verbose {
 			fmt.Printf("⏭️  Skipping %s: has exclusion topic\n", repoName)
 		}
 		return
 	}
 
-	// Detect ecosystems
-	ecosystems, err := s.detector.Detect(ctx, repoName)
+	snapshot := s.snapshots[repoName]
+
+	// Skip repositories that did not change since the last run
+	var headSHA string
+	if s.state != nil {
+		var err error
+		headSHA, err = s.headSHA(ctx, repo, snapshot)
+		if err != nil {
+			s.fail(run, repoName, err)
+			return
+		}
+		if !s.options.full && s.state.Unchanged(repoName, headSHA, s.templateKey(repo)) {
+			s.finished(repoName)
+			run.Skipped("unchanged since last run")
+			if s.options.verbose {
+				fmt.Printf("⏭️  Skipping %s: unchanged since last run\n", repoName)
+			}
+			return
+		}
+	}
+
+	// Detect ecosystems, from the root-level files when prefetched. Pruning
+	// needs every directory, so it always scans the full tree.
+	var ecosystems []detector.Ecosystem
+	var err error
+	if snapshot != nil && !s.options.prune {
+		ecosystems, err = s.detector.DetectRoot(ctx, repoName, snapshot.Paths, snapshot.Complete)
+	} else {
+		ecosystems, err = s.detector.Detect(ctx, repoName)
+	}
 	if err != nil {
-		s.reporter.AddFailedRepository(repo, err)
-		log.Printf("❌ Failed to detect ecosystems in %s: %v", repoName, err)
+		s.fail(run, repoName, fmt.Errorf("failed to detect ecosystems: %w", err))
 		return
 	}
 
 	if len(ecosystems) == 0 {
-		s.reporter.AddSkippedRepository(repo, "no supported ecosystems detected")
+		s.record(repo, headSHA, nil, nil)
+		s.finished(repoName)
+		run.Skipped("no supported ecosystems detected")
 		if s.options.verbose {
 			fmt.Printf("⏭️  Skipping %s: no supported ecosystems\n", repoName)
 		}
 		return
 	}
+	run.SetTemplates(s.merger.Variants(ecosystems, repo))
 
-	// Get existing configuration
-	existingConfig, err := s.client.GetExistingConfig(ctx, repoName)
+	target := &merger.Target{Repo: repo}
+	if s.merger.UsesTeam() {
+		target.Team, err = s.client.GetCodeOwnersTeam(ctx, repoName)
+		if err != nil {
+			s.fail(run, repoName, fmt.Errorf("failed to read CODEOWNERS: %w", err))
+			return
+		}
+	}
+
+	// Merge and apply, starting over from a fresh read when the file changes
+	// underneath us
+	var result *syncResult
+	for attempt := 1; ; attempt++ {
+		result, err = s.syncConfig(ctx, target, ecosystems, snapshot)
+		if err == nil || !errors.Is(err, githubClient.ErrConflict) || attempt >= maxConflictRetries {
+			break
+		}
+		// The prefetched config is stale now
+		snapshot = nil
+		if s.options.verbose {
+			fmt.Printf("🔁 %s: configuration changed concurrently, merging again\n", repoName)
+		}
+	}
 	if err != nil {
-		s.reporter.AddFailedRepository(repo, err)
-		log.Printf("❌ Failed to get existing config for %s: %v", repoName, err)
+		s.fail(run, repoName, err)
 		return
 	}
 
-	// Merge configurations
-	mergedConfig := s.merger.Merge(existingConfig, ecosystems)
-
-	// Check if update is needed
-	if existingConfig != nil && existingConfig.Equal(mergedConfig) {
-		s.reporter.AddProcessedRepository(repo, ecosystems, true, false)
+	if !result.updated {
 		if s.options.verbose {
 			fmt.Printf("✅ %s: already configured\n", repoName)
 		}
+
+		// A sync pull request left open is obsolete once the config matches
+		if s.options.createPR && !s.options.dryRun {
+			closed, err := s.client.CloseSyncPullRequest(ctx, repoName)
+			if err != nil {
+				log.Printf("⚠️  Failed to close obsolete pull request in %s: %v", repoName, err)
+			} else if closed {
+				fmt.Printf("🧹 %s: closed obsolete sync PR\n", repoName)
+			}
+		}
+		s.record(repo, headSHA, ecosystems, result.content)
+		s.finished(repoName)
+		run.Processed(ecosystems, false, "", nil)
 		return
 	}
 
-	// Apply configuration (if not dry run)
+	// A dry run changed nothing, so the next run must look again
 	if !s.options.dryRun {
-		if err := s.applyConfiguration(ctx, repoName, mergedConfig); err != nil {
-			s.reporter.AddFailedRepository(repo, err)
-			log.Printf("❌ Failed to apply config to %s: %v", repoName, err)
-			return
+		s.record(repo, headSHA, ecosystems, result.content)
+	}
+	s.finished(repoName)
+	run.SetPruned(result.pruned)
+	run.Processed(ecosystems, true, result.diff, result.changes)
+
+	names := make([]string, 0, len(ecosystems))
+	for _, eco := range ecosystems {
+		names = append(names, eco.Name)
+	}
+
+	// Print as a single write so concurrent output does not interleave
+	output := fmt.Sprintf("✅ %s: %s (ecosystems: %s)\n", repoName, result.action, strings.Join(names, ", "))
+	for _, update := range result.pruned {
+		output += fmt.Sprintf("🗑️  %s: pruned %s\n", repoName, update)
+	}
+	if s.options.dryRun {
+		output += formatDiff(result.diff)
+	}
+	fmt.Print(output)
+}
+
+// syncResult describes the outcome of syncConfig
+type syncResult struct {
+	// updated is false when the repository is already configured
+	updated bool
+	// content is the configuration written, or found when not updated
+	content []byte
+	action  string
+	diff    string
+	changes []config.Difference
+	// pruned describes the updates removed as stale
+	pruned []string
+}
+
+// syncConfig reads the existing configuration, from snapshot if not nil,
+// merges the detected ecosystems into it for target and applies the result
+// unless running dry. The error wraps githubClient.ErrConflict when the file
+// changed since it was read.
+func (s *Synchronizer) syncConfig(ctx context.Context, target *merger.Target, ecosystems []detector.Ecosystem, snapshot *githubClient.RepositorySnapshot) (*syncResult, error) {
+	repoName := target.Repo.GetName()
+
+	// Get existing configuration
+	var existingConfig *config.DependabotConfig
+	var existingContent []byte
+	var configPath string
+	var err error
+	if snapshot != nil && !snapshot.ConfigIncomplete {
+		existingConfig, existingContent, configPath, err = snapshot.ExistingConfig()
+	} else {
+		existingConfig, existingContent, configPath, err = s.client.GetExistingConfig(ctx, repoName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing config: %w", err)
+	}
+
+	// Merge configurations
+	mergedConfig := s.merger.Merge(existingConfig, ecosystems, target)
+	var pruned []string
+	if s.options.prune && existingConfig != nil {
+		for _, update := range merger.Prune(mergedConfig, ecosystems, existingContent) {
+			pruned = append(pruned, describeUpdate(update))
 		}
 	}
+	if s.options.collapseDirs {
+		merger.Collapse(mergedConfig, ecosystems)
+	}
 
-	s.reporter.AddProcessedRepository(repo, ecosystems, existingConfig != nil, true)
+	// Render the merged config onto the existing file, keeping its comments
+	// and formatting
+	content, changed, err := merger.Render(existingContent, mergedConfig, s.options.yamlIndent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render config: %w", err)
+	}
 
-	action := "would be updated"
+	// Check if update is needed
+	var changes []config.Difference
+	if existingConfig != nil {
+		changes = existingConfig.Diff(mergedConfig)
+	}
+	if existingConfig != nil && (len(changes) == 0 || !changed) {
+		return &syncResult{content: existingContent}, nil
+	}
+
+	result := &syncResult{
+		updated: true,
+		content: content,
+		action:  "would be updated",
+		// Show the proposed change for review
+		diff:    util.UnifiedDiff("a/"+configPath, "b/"+configPath, existingContent, content),
+		changes: changes,
+		pruned:  pruned,
+	}
+
+	// Apply configuration (if not dry run)
 	if !s.options.dryRun {
-		if s.options.createPR {
-			action = "PR created"
-		} else {
-			action = "updated"
+		result.action, err = s.applyConfiguration(ctx, repoName, configPath, mergedConfig, existingContent, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply config: %w", err)
 		}
 	}
 
-	fmt.Printf("✅ %s: %s (ecosystems: ", repoName, action)
-	for i, eco := range ecosystems {
-		if i > 0 {
-			fmt.Print(", ")
+	return result, nil
+}
+
+// headSHA returns the commit the default branch of a repository points at
+func (s *Synchronizer) headSHA(ctx context.Context, repo *github.Repository, snapshot *githubClient.RepositorySnapshot) (string, error) {
+	if snapshot != nil && snapshot.HeadSHA != "" {
+		return snapshot.HeadSHA, nil
+	}
+	sha, err := s.client.GetTreeSHA(ctx, repo.GetName(), repo.GetDefaultBranch())
+	if err != nil {
+		return "", fmt.Errorf("failed to get head commit: %w", err)
+	}
+	return sha, nil
+}
+
+// templateKey identifies the templates as they apply to repo: a change to
+// the templates or to the topics, visibility, language or default branch
+// of repo can change the result for the same head commit
+func (s *Synchronizer) templateKey(repo *github.Repository) string {
+	return s.templateHash + "/" + merger.Selection(repo)
+}
+
+// record remembers the outcome for a repository at headSHA, if a state file
+// is in use
+func (s *Synchronizer) record(repo *github.Repository, headSHA string, ecosystems []detector.Ecosystem, content []byte) {
+	if s.state == nil {
+		return
+	}
+
+	names := make([]string, 0, len(ecosystems))
+	for _, eco := range ecosystems {
+		names = append(names, eco.Name)
+	}
+
+	s.state.Put(repo.GetName(), state.Repository{
+		HeadSHA:      headSHA,
+		TemplateHash: s.templateKey(repo),
+		Ecosystems:   names,
+		ConfigHash:   state.Hash(content),
+		SyncedAt:     time.Now().UTC(),
+	})
+}
+
+// finished marks a repository as done in the checkpoint, if any. The
+// checkpoint is saved every few repositories, so a run that is killed
+// without a chance to save it can still be resumed.
+func (s *Synchronizer) finished(repoName string) {
+	if s.checkpoint == nil {
+		return
+	}
+	done := s.checkpoint.Done(repoName)
+	if s.options.checkpointEvery > 0 && done%s.options.checkpointEvery == 0 {
+		if err := s.checkpoint.Save(); err != nil {
+			log.Printf("⚠️  Failed to save checkpoint: %v", err)
 		}
-		fmt.Print(eco.Name)
 	}
-	fmt.Println(")")
 }
 
-// applyConfiguration applies the configuration to a repository
-func (s *Synchronizer) applyConfiguration(ctx context.Context, repoName string, cfg *config.DependabotConfig) error {
+// fail records a repository that could not be processed. Repositories the
+// credentials may not access are reported as skipped rather than failed,
+// as are those cut short by an interruption.
+func (s *Synchronizer) fail(run *reporter.Run, repoName string, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		run.Skipped("interrupted")
+	case errors.Is(err, githubClient.ErrForbidden):
+		run.Skipped("access denied")
+		log.Printf("🔒 Skipping %s: %v", repoName, err)
+	case errors.Is(err, githubClient.ErrRateLimited):
+		run.Failed(err)
+		log.Printf("⏳ %s: gave up after repeated rate limiting: %v", repoName, err)
+	case errors.Is(err, githubClient.ErrConflict):
+		run.Failed(err)
+		log.Printf("❌ %s: configuration kept changing during sync: %v", repoName, err)
+	default:
+		run.Failed(err)
+		log.Printf("❌ %s: %v", repoName, err)
+	}
+}
+
+// applyConfiguration applies the configuration to path, where it was read
+// from, and describes the action taken
+func (s *Synchronizer) applyConfiguration(ctx context.Context, repoName, path string, cfg *config.DependabotConfig, existingContent, content []byte) (string, error) {
 	if s.options.createPR {
-		return s.client.CreatePullRequest(ctx, repoName, cfg, s.options.yamlIndent)
+		pr, err := s.client.CreatePullRequest(ctx, repoName, path, cfg, content)
+		if err != nil {
+			return "", err
+		}
+		if pr.Updated {
+			return fmt.Sprintf("PR #%d updated", pr.Number), nil
+		}
+		return fmt.Sprintf("PR #%d created", pr.Number), nil
 	}
 
-	// Direct commit to main branch
-	content, err := util.MarshalYAML(cfg, s.options.yamlIndent)
+	// Direct commit to main branch, updating the existing file if present
+	current, sha, err := s.client.GetFileContent(ctx, repoName, path)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return "", err
+	}
+	// The SHA must belong to the content that was merged, or the write would
+	// overwrite a change made in between
+	if current != nil && !bytes.Equal(current, existingContent) {
+		return "", fmt.Errorf("%s changed since it was read: %w", path, githubClient.ErrConflict)
 	}
-
-	// Get existing file SHA if it exists
-	_, sha, _ := s.client.GetFileContent(ctx, repoName, ".github/dependabot.yml")
 
 	message := "Configure Dependabot for dependency updates"
 	if sha != "" {
 		message = "Update Dependabot configuration"
 	}
 
-	return s.client.CreateOrUpdateFile(ctx, repoName, ".github/dependabot.yml", message, content, sha)
+	return "updated", s.client.CreateOrUpdateFile(ctx, repoName, path, message, content, sha)
+}
+
+// describeUpdate names an update by ecosystem, directory and target branch
+func describeUpdate(update config.DependabotUpdate) string {
+	name := update.PackageEcosystem + " " + update.Directory
+	if update.TargetBranch != "" {
+		name += " (" + update.TargetBranch + ")"
+	}
+	return name
+}
+
+// formatDiff prepares a diff for stdout, colorizing it when attached to a terminal
+func formatDiff(diff string) string {
+	if isTerminal(os.Stdout) {
+		return util.ColorizeDiff(diff)
+	}
+	return diff
+}
+
+// isTerminal reports whether f is connected to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
 // parseFlags parses command-line flags
@@ -269,6 +709,13 @@ func parseFlags() *options {
 	opts := &options{}
 
 	flag.StringVar(&opts.token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub personal access token (or set GITHUB_TOKEN env var)")
+	flag.Int64Var(&opts.appID, "app-id", envInt64("GITHUB_APP_ID"), "GitHub App ID to authenticate as instead of a token (or set GITHUB_APP_ID env var)")
+	flag.StringVar(&opts.appPrivateKey, "app-private-key", os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH"), "Path to the GitHub App private key (or set GITHUB_APP_PRIVATE_KEY_PATH, or the key itself in GITHUB_APP_PRIVATE_KEY)")
+	flag.Int64Var(&opts.appInstallationID, "app-installation-id", envInt64("GITHUB_APP_INSTALLATION_ID"), "GitHub App installation ID, looked up from the organization if not set (or set GITHUB_APP_INSTALLATION_ID env var)")
+	flag.StringVar(&opts.baseURL, "base-url", os.Getenv("GITHUB_API_URL"), "GitHub Enterprise Server API URL, e.g. https://github.example.com/api/v3/ (or set GITHUB_API_URL env var)")
+	flag.StringVar(&opts.uploadURL, "upload-url", os.Getenv("GITHUB_UPLOAD_URL"), "GitHub Enterprise Server upload URL, defaults to /api/uploads/ on the host of the API URL (or set GITHUB_UPLOAD_URL env var)")
+	flag.StringVar(&opts.caBundle, "ca-bundle", os.Getenv("GITHUB_CA_BUNDLE"), "PEM file with additional CA certificates to trust (or set GITHUB_CA_BUNDLE env var)")
+	flag.StringVar(&opts.cacheDir, "cache-dir", "", "Directory to cache API responses in across runs; unchanged responses are revalidated without using the rate limit")
 	flag.StringVar(&opts.org, "org", os.Getenv("GITHUB_ORG"), "GitHub organization name (or set GITHUB_ORG env var)")
 	flag.BoolVar(&opts.dryRun, "dry-run", false, "Perform a dry run without making changes")
 	flag.BoolVar(&opts.createPR, "create-pr", false, "Create pull requests instead of direct commits")
@@ -280,6 +727,15 @@ func parseFlags() *options {
 	flag.BoolVar(&opts.verbose, "verbose", false, "Enable verbose output")
 	flag.BoolVar(&opts.version, "version", false, "Show version information")
 	flag.IntVar(&opts.yamlIndent, "yaml-indent", 2, "Number of spaces for YAML indentation")
+	flag.BoolVar(&opts.graphQL, "graphql", false, "Fetch repository metadata, existing configs and root files in batched GraphQL queries, scanning the full tree only where needed")
+	flag.StringVar(&opts.stateFile, "state-file", "", "JSON file remembering each repository's head commit, so repositories unchanged since the last run are skipped")
+	flag.BoolVar(&opts.full, "full", false, "Process every repository even if the state file says it is unchanged")
+	flag.StringVar(&opts.checkpointFile, "checkpoint-file", "", "File recording finished repositories when a run is interrupted (default <report-dir>/checkpoint.json)")
+	flag.IntVar(&opts.checkpointEvery, "checkpoint-every", 10, "Save the checkpoint each time this many repositories are finished (0 saves it only when interrupted)")
+	flag.BoolVar(&opts.resume, "resume", false, "Continue an interrupted run with the repositories its checkpoint does not list")
+	flag.BoolVar(&opts.prune, "prune", false, "Remove updates for ecosystems and directories that no longer exist, unless annotated with # dependabot-sync: keep")
+	flag.BoolVar(&opts.collapseDirs, "collapse-directories", false, "Emit one update per ecosystem listing its directories as glob patterns instead of one update per directory")
+	flag.StringVar(&opts.localPath, "local", "", "Path to a local repository checkout to configure instead of the GitHub organization")
 
 	// Custom flag for repositories list
 	var reposList string
@@ -306,18 +762,33 @@ func parseFlags() *options {
 
 // validateOptions validates the provided options
 func validateOptions(opts *options) error {
-	if opts.token == "" {
-		return fmt.Errorf("GitHub token is required (use -token flag or GITHUB_TOKEN env var)")
-	}
+	if opts.localPath != "" {
+		info, err := os.Stat(opts.localPath)
+		if err != nil || !info.IsDir() {
+			return fmt.Errorf("local checkout is not a directory: %s", opts.localPath)
+		}
+	} else {
+		if opts.appID != 0 {
+			if opts.appPrivateKey == "" && os.Getenv("GITHUB_APP_PRIVATE_KEY") == "" {
+				return fmt.Errorf("GitHub App private key is required (use -app-private-key flag or GITHUB_APP_PRIVATE_KEY env var)")
+			}
+		} else if opts.token == "" {
+			return fmt.Errorf("GitHub token or App ID is required (use -token flag or GITHUB_TOKEN env var, or -app-id)")
+		}
 
-	if opts.org == "" {
-		return fmt.Errorf("GitHub organization is required (use -org flag or GITHUB_ORG env var)")
+		if opts.org == "" {
+			return fmt.Errorf("GitHub organization is required (use -org flag or GITHUB_ORG env var)")
+		}
 	}
 
 	if opts.concurrency < 1 {
 		return fmt.Errorf("concurrency must be at least 1")
 	}
 
+	if opts.checkpointEvery < 0 {
+		return fmt.Errorf("checkpoint-every must not be negative")
+	}
+
 	if opts.concurrency > 50 {
 		return fmt.Errorf("concurrency should not exceed 50 to avoid rate limiting")
 	}
@@ -342,6 +813,45 @@ func validateOptions(opts *options) error {
 	return nil
 }
 
+// newGitHubClient creates a GitHub client authenticated as a GitHub App when
+// an App ID is configured, or with the token otherwise
+func newGitHubClient(opts *options) (*githubClient.Client, error) {
+	endpoint := githubClient.Endpoint{
+		BaseURL:   opts.baseURL,
+		UploadURL: opts.uploadURL,
+		CABundle:  opts.caBundle,
+		CacheDir:  opts.cacheDir,
+	}
+
+	if opts.appID == 0 {
+		return githubClient.NewClient(opts.token, opts.org, endpoint)
+	}
+
+	key := []byte(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+	if opts.appPrivateKey != "" {
+		data, err := os.ReadFile(opts.appPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+		key = data
+	}
+
+	return githubClient.NewAppClient(githubClient.AppCredentials{
+		AppID:          opts.appID,
+		PrivateKey:     key,
+		InstallationID: opts.appInstallationID,
+	}, opts.org, endpoint)
+}
+
+// envInt64 reads an integer environment variable, returning 0 when unset or invalid
+func envInt64(name string) int64 {
+	value, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
 // parseCSV parses a comma-separated string into a slice
 func parseCSV(s string) []string {
 	if s == "" {
@@ -0,0 +1,1288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	githubClient "github.com/enthus-appdev/dependabot-config-manager/internal/github"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/github/githubtest"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/merger"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/reporter"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/state"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/util"
+	"github.com/google/go-github/v50/github"
+	"gopkg.in/yaml.v3"
+)
+
+const testOrg = "acme"
+
+// newTestSynchronizer wires a Synchronizer to a fake GitHub server serving
+// the fixture repositories in testdata/repos
+func newTestSynchronizer(t *testing.T, opts *options) (*Synchronizer, *githubtest.Server) {
+	t.Helper()
+
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	// Count API calls the way the production client does
+	httpClient := &http.Client{Transport: githubClient.CountingTransport(srv.Client().Transport)}
+	gh, err := github.NewEnterpriseClient(srv.URL, srv.URL, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newSynchronizer(t, githubClient.NewClientFromGitHub(gh, testOrg), opts), srv
+}
+
+// newSynchronizer creates a synchronizer using client with the repository
+// templates and test defaults
+func newSynchronizer(t *testing.T, client githubClient.API, opts *options) *Synchronizer {
+	t.Helper()
+
+	mrg, err := merger.New("../../configs")
+	if err != nil {
+		t.Fatalf("failed to initialize merger: %v", err)
+	}
+
+	opts.org = testOrg
+	opts.excludeArchived = true
+	opts.concurrency = 4
+	opts.yamlIndent = 2
+	opts.reportDir = t.TempDir()
+
+	syncer := &Synchronizer{
+		client:   client,
+		detector: detector.New(client),
+		merger:   mrg,
+		reporter: reporter.New(testOrg, opts.reportDir, false),
+		options:  opts,
+		wg:       &sync.WaitGroup{},
+	}
+	syncer.semaphore = util.NewAdaptiveSemaphore(syncer.concurrency)
+	return syncer
+}
+
+// parseConfig decodes committed dependabot.yml content
+func parseConfig(t *testing.T, content []byte) *config.DependabotConfig {
+	t.Helper()
+
+	var cfg config.DependabotConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		t.Fatalf("committed config is not valid YAML: %v", err)
+	}
+	return &cfg
+}
+
+// resetReporter gives the synchronizer a fresh reporter, so reportStatuses
+// only sees the next run
+func resetReporter(t *testing.T, syncer *Synchronizer) {
+	t.Helper()
+
+	syncer.options.reportDir = t.TempDir()
+	syncer.reporter = reporter.New(testOrg, syncer.options.reportDir, false)
+}
+
+// saveReport saves the JSON report of a run and reads it back
+func saveReport(t *testing.T, syncer *Synchronizer) reporter.Report {
+	t.Helper()
+
+	if err := syncer.reporter.SaveReport("json"); err != nil {
+		t.Fatalf("SaveReport() error = %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(syncer.options.reportDir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a single JSON report, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report reporter.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	return report
+}
+
+// reportStatuses saves the JSON report of a run and returns the status of
+// each repository in it
+func reportStatuses(t *testing.T, syncer *Synchronizer) map[string]string {
+	t.Helper()
+
+	report := saveReport(t, syncer)
+	statuses := make(map[string]string)
+	for _, detail := range report.RepositoryDetails {
+		statuses[detail.Name] = detail.Status
+		if detail.SkipReason != "" {
+			statuses[detail.Name] += ": " + detail.SkipReason
+		}
+	}
+	return statuses
+}
+
+// reportDiff returns the diff reported for a repository
+func reportDiff(t *testing.T, syncer *Synchronizer, repo string) string {
+	t.Helper()
+
+	for _, detail := range saveReport(t, syncer).RepositoryDetails {
+		if detail.Name == repo {
+			return detail.Diff
+		}
+	}
+	t.Fatalf("%s: not in the report", repo)
+	return ""
+}
+
+// ecosystemsOf lists the package ecosystems of a config in order
+func ecosystemsOf(cfg *config.DependabotConfig) []string {
+	var ecosystems []string
+	for _, update := range cfg.Updates {
+		ecosystems = append(ecosystems, update.PackageEcosystem)
+	}
+	return ecosystems
+}
+
+func TestSynchronizer_Run_DirectCommit(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{})
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// New configuration is committed to the default branch
+	commits := srv.Commits("web-app")
+	if len(commits) != 1 {
+		t.Fatalf("web-app: expected 1 commit, got %d", len(commits))
+	}
+	if commits[0].Branch != "main" || commits[0].Path != ".github/dependabot.yml" {
+		t.Errorf("web-app: unexpected commit target %s:%s", commits[0].Branch, commits[0].Path)
+	}
+	if commits[0].Message != "Configure Dependabot for dependency updates" {
+		t.Errorf("web-app: unexpected commit message %q", commits[0].Message)
+	}
+
+	cfg := parseConfig(t, commits[0].Content)
+	got := strings.Join(ecosystemsOf(cfg), ",")
+	if got != "docker,github-actions,npm" {
+		t.Errorf("web-app: expected ecosystems docker,github-actions,npm, got %s", got)
+	}
+
+	// Existing configuration is updated on the non-main default branch
+	commits = srv.Commits("api-service")
+	if len(commits) != 1 {
+		t.Fatalf("api-service: expected 1 commit, got %d", len(commits))
+	}
+	if commits[0].Branch != "develop" {
+		t.Errorf("api-service: expected commit on develop, got %s", commits[0].Branch)
+	}
+	if commits[0].Message != "Update Dependabot configuration" {
+		t.Errorf("api-service: unexpected commit message %q", commits[0].Message)
+	}
+
+	cfg = parseConfig(t, commits[0].Content)
+	if len(cfg.Updates) != 1 {
+		t.Fatalf("api-service: expected 1 update, got %d", len(cfg.Updates))
+	}
+	if cfg.Updates[0].Schedule.Interval != "daily" {
+		t.Errorf("api-service: schedule should come from template, got %s", cfg.Updates[0].Schedule.Interval)
+	}
+	labels := strings.Join(cfg.Updates[0].Labels, ",")
+	if labels != "backend,dependencies,golang" {
+		t.Errorf("api-service: expected merged labels, got %s", labels)
+	}
+
+	// Skipped and excluded repositories are left untouched
+	for _, repo := range []string{"legacy-docs", "opted-out", "old-archive"} {
+		if commits := srv.Commits(repo); len(commits) != 0 {
+			t.Errorf("%s: expected no commits, got %d", repo, len(commits))
+		}
+	}
+}
+
+func TestSynchronizer_Run_KeepsYamlExtension(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   options
+		branch string
+	}{
+		{name: "direct commit", branch: "main"},
+		{name: "graphql", opts: options{graphQL: true}, branch: "main"},
+		{name: "pull request", opts: options{createPR: true}, branch: githubClient.SyncBranch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syncer, srv := newTestSynchronizer(t, &tt.opts)
+			existing := []byte("version: 2\nupdates:\n  - package-ecosystem: npm\n    directory: /\n    schedule:\n      interval: monthly\n")
+			if err := srv.PutFile("web-app", "main", ".github/dependabot.yaml", existing); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := syncer.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			// The configuration is updated where Dependabot reads it, without
+			// a second file next to it
+			commits := srv.Commits("web-app")
+			if len(commits) != 1 {
+				t.Fatalf("expected 1 commit, got %d", len(commits))
+			}
+			if commits[0].Branch != tt.branch || commits[0].Path != ".github/dependabot.yaml" {
+				t.Errorf("unexpected commit target %s:%s", commits[0].Branch, commits[0].Path)
+			}
+			if _, ok := srv.File("web-app", tt.branch, ".github/dependabot.yml"); ok {
+				t.Errorf("expected no .github/dependabot.yml on %s", tt.branch)
+			}
+
+			// The proposed change is shown against the same file
+			if diff := reportDiff(t, syncer, "web-app"); !strings.HasPrefix(diff, "--- a/.github/dependabot.yaml\n+++ b/.github/dependabot.yaml\n") {
+				t.Errorf("expected the diff of .github/dependabot.yaml, got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSynchronizer_Run_CreatePR(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{createPR: true})
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	prs := srv.PullRequests("web-app")
+	if len(prs) != 1 {
+		t.Fatalf("web-app: expected 1 pull request, got %d", len(prs))
+	}
+	if prs[0].Base != "main" || prs[0].Head != githubClient.SyncBranch {
+		t.Errorf("web-app: unexpected pull request %s <- %s", prs[0].Base, prs[0].Head)
+	}
+	if len(prs[0].Labels) != 1 || prs[0].Labels[0] != githubClient.SyncLabel {
+		t.Errorf("web-app: expected pull request labeled %s, got %v", githubClient.SyncLabel, prs[0].Labels)
+	}
+
+	commits := srv.Commits("web-app")
+	if len(commits) != 1 || commits[0].Branch != prs[0].Head {
+		t.Fatalf("web-app: expected a single commit on the PR branch, got %+v", commits)
+	}
+	if _, ok := srv.File("web-app", "main", ".github/dependabot.yml"); ok {
+		t.Errorf("web-app: default branch should not be modified when creating a PR")
+	}
+
+	prs = srv.PullRequests("api-service")
+	if len(prs) != 1 || prs[0].Base != "develop" {
+		t.Errorf("api-service: expected 1 pull request against develop, got %+v", prs)
+	}
+}
+
+func TestSynchronizer_Run_UpdatesExistingPR(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{createPR: true})
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("first Run() error = %v", err)
+	}
+
+	// A second run with nothing changed reuses the open pull request as is
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if prs := srv.PullRequests("api-service"); len(prs) != 1 {
+		t.Fatalf("api-service: expected 1 pull request after rerun, got %d", len(prs))
+	}
+	if commits := srv.Commits("api-service"); len(commits) != 1 {
+		t.Errorf("api-service: expected no new commit for unchanged config, got %d commits", len(commits))
+	}
+
+	// Someone edits the config on the default branch; the PR branch is reset
+	// and the new merge result pushed to it
+	edited := []byte("version: 2\nupdates:\n  - package-ecosystem: \"gomod\"\n    directory: \"/\"\n    schedule:\n      interval: \"weekly\"\n    labels:\n      - \"api\"\n")
+	if err := srv.PutFile("api-service", "develop", ".github/dependabot.yml", edited); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("third Run() error = %v", err)
+	}
+
+	prs := srv.PullRequests("api-service")
+	if len(prs) != 1 || prs[0].State != "open" {
+		t.Fatalf("api-service: expected the single pull request to stay open, got %+v", prs)
+	}
+	commits := srv.Commits("api-service")
+	if len(commits) != 2 || commits[1].Branch != githubClient.SyncBranch {
+		t.Fatalf("api-service: expected a second commit on %s, got %+v", githubClient.SyncBranch, commits)
+	}
+	content, _ := srv.File("api-service", githubClient.SyncBranch, ".github/dependabot.yml")
+	if !strings.Contains(string(content), "- \"api\"\n") || strings.Contains(string(content), "backend") {
+		t.Errorf("api-service: PR branch should be rebuilt from the edited config, got:\n%s", content)
+	}
+	if !strings.Contains(string(content), "package-ecosystem: \"gomod\"\n") {
+		t.Errorf("api-service: PR branch should keep the formatting of the edited config, got:\n%s", content)
+	}
+
+	// Once the change lands on the default branch the pull request is obsolete
+	// and gets closed
+	merged, _ := srv.File("web-app", githubClient.SyncBranch, ".github/dependabot.yml")
+	if err := srv.PutFile("web-app", "main", ".github/dependabot.yml", merged); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("fourth Run() error = %v", err)
+	}
+
+	prs = srv.PullRequests("web-app")
+	if len(prs) != 1 || prs[0].State != "closed" {
+		t.Errorf("web-app: expected the obsolete pull request to be closed, got %+v", prs)
+	}
+	if _, ok := srv.File("web-app", githubClient.SyncBranch, ".github/dependabot.yml"); ok {
+		t.Errorf("web-app: expected the sync branch to be deleted")
+	}
+}
+
+func TestSynchronizer_Run_LeavesForeignBranches(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{createPR: true})
+
+	// A labeled pull request from a fork using the sync branch name, and a
+	// labeled pull request someone opened from a branch of their own
+	if _, err := srv.OpenPullRequest("web-app", githubtest.PullRequest{
+		Head:     githubClient.SyncBranch,
+		HeadRepo: "someone/web-app",
+		Labels:   []string{githubClient.SyncLabel},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.OpenPullRequest("web-app", githubtest.PullRequest{
+		Head:   "feature/dependabot",
+		Labels: []string{githubClient.SyncLabel},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	prs := srv.PullRequests("web-app")
+	if len(prs) != 3 {
+		t.Fatalf("web-app: expected a new sync pull request, got %+v", prs)
+	}
+	for _, pr := range prs[:2] {
+		if pr.State != "open" {
+			t.Errorf("web-app: expected labeled pull request #%d to stay open, got %s", pr.Number, pr.State)
+		}
+	}
+	if prs[2].Head != githubClient.SyncBranch || prs[2].State != "open" {
+		t.Errorf("web-app: expected an open pull request from %s, got %+v", githubClient.SyncBranch, prs[2])
+	}
+	if !srv.HasBranch("web-app", "feature/dependabot") {
+		t.Errorf("web-app: branch of a pull request matched by label only should not be deleted")
+	}
+	commits := srv.Commits("web-app")
+	if len(commits) != 1 || commits[0].Branch != githubClient.SyncBranch {
+		t.Errorf("web-app: expected a single commit on %s, got %+v", githubClient.SyncBranch, commits)
+	}
+
+	// Once the configuration lands, only the sync pull request is closed
+	merged, _ := srv.File("web-app", githubClient.SyncBranch, ".github/dependabot.yml")
+	if err := srv.PutFile("web-app", "main", ".github/dependabot.yml", merged); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+
+	prs = srv.PullRequests("web-app")
+	if len(prs) != 3 || prs[2].State != "closed" {
+		t.Fatalf("web-app: expected the sync pull request to be closed, got %+v", prs)
+	}
+	for _, pr := range prs[:2] {
+		if pr.State != "open" {
+			t.Errorf("web-app: expected labeled pull request #%d to stay open, got %s", pr.Number, pr.State)
+		}
+	}
+}
+
+func TestSynchronizer_Run_DryRun(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{dryRun: true})
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, repo := range []string{"web-app", "api-service", "legacy-docs", "opted-out", "old-archive"} {
+		if commits := srv.Commits(repo); len(commits) != 0 {
+			t.Errorf("%s: expected no commits in dry-run, got %d", repo, len(commits))
+		}
+		if prs := srv.PullRequests(repo); len(prs) != 0 {
+			t.Errorf("%s: expected no pull requests in dry-run, got %d", repo, len(prs))
+		}
+	}
+
+	// The JSON report carries the proposed diff for review
+	if err := syncer.reporter.SaveReport("json"); err != nil {
+		t.Fatalf("SaveReport() error = %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(syncer.options.reportDir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a single JSON report, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report reporter.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+
+	diffs := make(map[string]string)
+	changes := make(map[string][]string)
+	for _, detail := range report.RepositoryDetails {
+		diffs[detail.Name] = detail.Diff
+		if detail.StartedAt.IsZero() || (detail.Status != "skipped" && detail.APICalls == 0) {
+			t.Errorf("%s: expected timing and API calls in report, got %+v", detail.Name, detail)
+		}
+		for _, change := range detail.Changes {
+			changes[detail.Name] = append(changes[detail.Name], change.String())
+		}
+	}
+	if !strings.Contains(diffs["api-service"], "-      interval: \"monthly\"\n+      interval: \"daily\"\n") {
+		t.Errorf("api-service: expected schedule change in diff, got:\n%s", diffs["api-service"])
+	}
+	if !strings.HasPrefix(diffs["web-app"], "--- a/.github/dependabot.yml\n+++ b/.github/dependabot.yml\n@@ -0,0 +1,") {
+		t.Errorf("web-app: expected new-file diff, got:\n%s", diffs["web-app"])
+	}
+	if !slices.Contains(changes["api-service"], "updates[gomod /].schedule.interval: monthly → daily") {
+		t.Errorf("api-service: expected schedule change in report, got %q", changes["api-service"])
+	}
+	if len(changes["web-app"]) != 0 {
+		t.Errorf("web-app: new files should not list field changes, got %q", changes["web-app"])
+	}
+	if diffs["legacy-docs"] != "" {
+		t.Errorf("legacy-docs: expected no diff, got:\n%s", diffs["legacy-docs"])
+	}
+}
+
+func TestSynchronizer_Run_Enterprise(t *testing.T) {
+	srv, err := githubtest.NewTLSServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	// The server certificate stands in for a private enterprise CA
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caBundle, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &options{token: "test-token", org: testOrg, baseURL: srv.URL, caBundle: caBundle}
+	client, err := newGitHubClient(opts)
+	if err != nil {
+		t.Fatalf("newGitHubClient() error = %v", err)
+	}
+
+	syncer := newSynchronizer(t, client, opts)
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, repo := range []string{"web-app", "api-service"} {
+		if commits := srv.Commits(repo); len(commits) != 1 {
+			t.Errorf("%s: expected 1 commit through the enterprise endpoint, got %d", repo, len(commits))
+		}
+	}
+
+	// Without the CA bundle the self-signed certificate is rejected
+	untrusted, err := newGitHubClient(&options{token: "test-token", org: testOrg, baseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("newGitHubClient() error = %v", err)
+	}
+	if _, err := untrusted.GetRepository(context.Background(), "web-app"); err == nil {
+		t.Error("expected a TLS error without the CA bundle")
+	}
+}
+
+// racingClient changes the configuration on the default branch right before
+// the first write to it, as if someone pushed while the sync was running
+type racingClient struct {
+	githubClient.API
+	srv    *githubtest.Server
+	branch string
+	edit   []byte
+	raced  bool
+}
+
+func (c *racingClient) CreateOrUpdateFile(ctx context.Context, repo, path, message string, content []byte, sha string) error {
+	if !c.raced {
+		c.raced = true
+		if err := c.srv.PutFile(repo, c.branch, path, c.edit); err != nil {
+			return err
+		}
+	}
+	return c.API.CreateOrUpdateFile(ctx, repo, path, message, content, sha)
+}
+
+func TestSynchronizer_Run_ConflictMergesAgain(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	edited := []byte("version: 2\nupdates:\n  - package-ecosystem: \"gomod\"\n    directory: \"/\"\n    schedule:\n      interval: \"weekly\"\n    labels:\n      - \"api\"\n")
+	client := &racingClient{
+		API:    githubClient.NewClientFromGitHub(srv.NewClient(), testOrg),
+		srv:    srv,
+		branch: "develop",
+		edit:   edited,
+	}
+
+	syncer := newSynchronizer(t, client, &options{repositories: []string{"api-service"}})
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The first write is rejected for its stale SHA; the retry merges onto
+	// the concurrent edit instead of overwriting it
+	commits := srv.Commits("api-service")
+	if len(commits) != 1 {
+		t.Fatalf("api-service: expected 1 commit, got %d", len(commits))
+	}
+	content := string(commits[0].Content)
+	if !strings.Contains(content, "- \"api\"\n") || strings.Contains(content, "backend") {
+		t.Errorf("api-service: expected the commit to be merged onto the concurrent edit, got:\n%s", content)
+	}
+	if !strings.Contains(content, "interval: \"daily\"") {
+		t.Errorf("api-service: expected the template schedule to be applied, got:\n%s", content)
+	}
+}
+
+// throttlingTransport answers the first tree request with secondary rate
+// limits, as if GitHub asked to slow down in the middle of a run
+type throttlingTransport struct {
+	base  http.RoundTripper
+	mu    sync.Mutex
+	times int
+}
+
+func (t *throttlingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	throttle := t.times > 0 && strings.Contains(req.URL.Path, "/git/trees/")
+	if throttle {
+		t.times--
+	}
+	t.mu.Unlock()
+
+	if !throttle {
+		return t.base.RoundTrip(req)
+	}
+	return &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header:     http.Header{"Retry-After": []string{"0"}},
+		Body:       io.NopCloser(strings.NewReader(`{"message":"You have exceeded a secondary rate limit."}`)),
+		Request:    req,
+	}, nil
+}
+
+// concurrencyClient records how many repositories are detected at once. The
+// first detection is short and the second long, so a repository admitted
+// when the first finishes overlaps the second unless concurrency dropped.
+type concurrencyClient struct {
+	githubClient.API
+	mu      sync.Mutex
+	calls   int
+	running int
+	peaks   []int
+}
+
+func (c *concurrencyClient) GetTree(ctx context.Context, repo string) ([]string, error) {
+	c.mu.Lock()
+	c.calls++
+	call := c.calls
+	c.running++
+	c.peaks = append(c.peaks, c.running)
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.running--
+		c.mu.Unlock()
+	}()
+
+	paths, err := c.API.GetTree(ctx, repo)
+	if call == 2 {
+		time.Sleep(300 * time.Millisecond)
+	}
+	return paths, err
+}
+
+func TestSynchronizer_Run_ThrottleReducesConcurrency(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	limiter := githubClient.NewRateLimiter()
+	throttler := &throttlingTransport{base: srv.Client().Transport, times: 2}
+	gh, err := github.NewEnterpriseClient(srv.URL, srv.URL, &http.Client{Transport: limiter.Transport(throttler)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &concurrencyClient{API: githubClient.NewClientFromGitHub(gh, testOrg)}
+
+	syncer := newSynchronizer(t, client, &options{
+		dryRun:       true,
+		repositories: []string{"web-app", "api-service", "legacy-docs"},
+	})
+	syncer.options.concurrency = 2
+	syncer.limiter = limiter
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(client.peaks) != 3 {
+		t.Fatalf("expected 3 repositories detected, got %d", len(client.peaks))
+	}
+	// The third repository waits for both running ones to finish
+	if got := client.peaks[2]; got != 1 {
+		t.Errorf("expected 1 repository in flight after throttling, got %d", got)
+	}
+	if got := syncer.semaphore.Width(); got != 1 {
+		t.Errorf("semaphore width after throttling = %d, want 1", got)
+	}
+}
+
+// recordingTransport records the path of every request
+type recordingTransport struct {
+	base  http.RoundTripper
+	mu    sync.Mutex
+	paths []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.paths = append(t.paths, req.Method+" "+req.URL.Path)
+	t.mu.Unlock()
+	return t.base.RoundTrip(req)
+}
+
+// count returns how many recorded requests contain substr
+func (t *recordingTransport) count(substr string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, p := range t.paths {
+		if strings.Contains(p, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSynchronizer_Run_GraphQL(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	transport := &recordingTransport{base: srv.Client().Transport}
+	gh, err := github.NewEnterpriseClient(srv.URL, srv.URL, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files in subdirectories are not part of the snapshot
+	if err := srv.PutFile("legacy-docs", "main", "docs/index.md", []byte("# Docs\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	syncer := newSynchronizer(t, githubClient.NewClientFromGitHub(gh, testOrg), &options{dryRun: true, graphQL: true})
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// One query covers all repositories
+	if n := transport.count("POST /api/graphql"); n != 1 {
+		t.Errorf("expected 1 GraphQL query, got %d", n)
+	}
+
+	// Repositories without subdirectories need neither the tree nor the
+	// config contents; legacy-docs has one and falls back to the full tree
+	for _, repo := range []string{"web-app", "api-service"} {
+		if n := transport.count("/repos/" + testOrg + "/" + repo + "/git/trees/"); n != 0 {
+			t.Errorf("%s: expected no tree request, got %d", repo, n)
+		}
+		if n := transport.count("/repos/" + testOrg + "/" + repo + "/contents/"); n != 0 {
+			t.Errorf("%s: expected no contents request, got %d", repo, n)
+		}
+	}
+	if n := transport.count("/repos/" + testOrg + "/legacy-docs/git/trees/"); n != 1 {
+		t.Errorf("legacy-docs: expected a full tree scan, got %d tree requests", n)
+	}
+
+	// The outcome matches the REST-only run
+	statuses := reportStatuses(t, syncer)
+	want := map[string]string{
+		"web-app":     "updated",
+		"api-service": "updated",
+		"legacy-docs": "skipped: no supported ecosystems detected",
+		"opted-out":   "skipped: has exclusion topic",
+	}
+	for repo, status := range want {
+		if statuses[repo] != status {
+			t.Errorf("%s: expected status %s, got %q", repo, status, statuses[repo])
+		}
+	}
+}
+
+func TestSynchronizer_Run_GraphQLMatchesREST(t *testing.T) {
+	// A root manifest next to manifests further down
+	detected := make(map[bool]string)
+	for _, graphQL := range []bool{false, true} {
+		syncer, srv := newTestSynchronizer(t, &options{dryRun: true, graphQL: graphQL})
+		for _, path := range []string{"backend/go.mod", "deploy/Dockerfile"} {
+			if err := srv.PutFile("web-app", "main", path, []byte("placeholder\n")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := syncer.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		for _, detail := range saveReport(t, syncer).RepositoryDetails {
+			if detail.Name != "web-app" {
+				continue
+			}
+			var found []string
+			for _, eco := range detail.DetectedEcosystems {
+				found = append(found, eco.Name+" "+strings.Join(eco.Directories, ","))
+			}
+			slices.Sort(found)
+			detected[graphQL] = strings.Join(found, "; ")
+		}
+	}
+
+	want := "docker /; github-actions /; gomod /backend; npm /"
+	if detected[false] != want {
+		t.Errorf("REST: expected %s, got %s", want, detected[false])
+	}
+	if detected[true] != detected[false] {
+		t.Errorf("GraphQL: expected %s as with REST, got %s", detected[false], detected[true])
+	}
+}
+
+func TestSynchronizer_Run_CountsAPICalls(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	transport := &recordingTransport{base: srv.Client().Transport}
+	httpClient := &http.Client{Transport: githubClient.CountingTransport(transport)}
+	gh, err := github.NewEnterpriseClient(srv.URL, srv.URL, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncer := newSynchronizer(t, githubClient.NewClientFromGitHub(gh, testOrg), &options{dryRun: true, graphQL: true})
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Listing the repositories and the GraphQL query count as well
+	report := saveReport(t, syncer)
+	if want := transport.count(""); report.Summary.APICalls != want {
+		t.Errorf("expected %d API calls, got %d", want, report.Summary.APICalls)
+	}
+}
+
+func TestSynchronizer_Run_Cache(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	cacheDir := t.TempDir()
+	run := func() *githubClient.Cache {
+		t.Helper()
+
+		opts := &options{token: "test-token", org: testOrg, baseURL: srv.URL, cacheDir: cacheDir, dryRun: true}
+		client, err := newGitHubClient(opts)
+		if err != nil {
+			t.Fatalf("newGitHubClient() error = %v", err)
+		}
+		if err := newSynchronizer(t, client, opts).Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return client.Cache()
+	}
+
+	if hits, misses := run().Stats(); hits != 0 || misses == 0 {
+		t.Fatalf("first run: expected only downloads, got %d hits and %d misses", hits, misses)
+	}
+
+	// Nothing changed, so the next run's trees and configs are revalidated
+	hits, misses := run().Stats()
+	if hits == 0 || misses != 0 {
+		t.Errorf("second run: expected only cache hits, got %d hits and %d misses", hits, misses)
+	}
+
+	// A changed file is downloaded again
+	if err := srv.PutFile("api-service", "develop", ".github/dependabot.yml", []byte("version: 2\nupdates: []\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, misses := run().Stats(); misses == 0 {
+		t.Error("third run: expected the changed repository to be downloaded again")
+	}
+}
+
+func TestSynchronizer_Run_StateFileLooksUpHead(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	transport := &recordingTransport{base: srv.Client().Transport}
+	gh, err := github.NewEnterpriseClient(srv.URL, srv.URL, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncer := newSynchronizer(t, githubClient.NewClientFromGitHub(gh, testOrg), &options{dryRun: true})
+	syncer.state, err = state.Load(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The listing already names the default branch, so its head is read
+	// without asking for the repository again
+	if n := transport.count("/git/ref/heads/develop"); n != 1 {
+		t.Errorf("api-service: expected 1 request for the head of develop, got %d", n)
+	}
+	for _, repo := range []string{"web-app", "api-service"} {
+		if slices.Contains(transport.paths, "GET /api/v3/repos/"+testOrg+"/"+repo) {
+			t.Errorf("%s: expected no repository lookup", repo)
+		}
+	}
+}
+
+func TestSynchronizer_Run_StateFile(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	syncer, srv := newTestSynchronizer(t, &options{createPR: true, stateFile: statePath})
+
+	run := func() map[string]string {
+		t.Helper()
+
+		resetReporter(t, syncer)
+		st, err := state.Load(statePath)
+		if err != nil {
+			t.Fatalf("state.Load() error = %v", err)
+		}
+		syncer.state = st
+		syncer.templateHash = syncer.merger.Fingerprint()
+
+		if err := syncer.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if err := st.Save(); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return reportStatuses(t, syncer)
+	}
+
+	first := run()
+	if first["web-app"] != "updated" || first["api-service"] != "updated" {
+		t.Fatalf("first run: expected both repositories to be updated, got %v", first)
+	}
+
+	// Opening pull requests leaves the default branches alone, so nothing
+	// is looked at again
+	second := run()
+	for _, repo := range []string{"web-app", "api-service", "legacy-docs"} {
+		if second[repo] != "skipped: unchanged since last run" {
+			t.Errorf("second run: expected %s to be skipped as unchanged, got %q", repo, second[repo])
+		}
+	}
+
+	// A push to the default branch brings the repository back
+	if err := srv.PutFile("web-app", "main", "README.md", []byte("# web-app\n")); err != nil {
+		t.Fatal(err)
+	}
+	third := run()
+	if third["web-app"] != "updated" || third["api-service"] != "skipped: unchanged since last run" {
+		t.Errorf("third run: expected only web-app to be processed, got %v", third)
+	}
+
+	// Topics select variants and layers, so changing only them brings the
+	// repository back as well
+	if err := srv.SetTopics("api-service", []string{"team-data"}); err != nil {
+		t.Fatal(err)
+	}
+	fourth := run()
+	if fourth["api-service"] != "updated" || fourth["web-app"] != "skipped: unchanged since last run" {
+		t.Errorf("fourth run: expected only api-service to be processed, got %v", fourth)
+	}
+
+	// -full ignores the state
+	syncer.options.full = true
+	fifth := run()
+	if fifth["api-service"] != "updated" || fifth["legacy-docs"] != "skipped: no supported ecosystems detected" {
+		t.Errorf("full run: expected every repository to be processed, got %v", fifth)
+	}
+}
+
+// interruptingClient cancels the run when a repository is about to be
+// written, as if the job was stopped in the middle
+type interruptingClient struct {
+	githubClient.API
+	repo   string
+	cancel context.CancelFunc
+}
+
+func (c *interruptingClient) CreateOrUpdateFile(ctx context.Context, repo, path, message string, content []byte, sha string) error {
+	if repo == c.repo {
+		c.cancel()
+		return ctx.Err()
+	}
+	return c.API.CreateOrUpdateFile(ctx, repo, path, message, content, sha)
+}
+
+func TestSynchronizer_Run_Resume(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &interruptingClient{
+		API:    githubClient.NewClientFromGitHub(srv.NewClient(), testOrg),
+		repo:   "api-service",
+		cancel: cancel,
+	}
+	syncer := newSynchronizer(t, client, &options{})
+	syncer.checkpoint = state.NewCheckpoint(checkpointPath, testOrg)
+
+	err = syncer.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context canceled", err)
+	}
+
+	// The interrupted repository is neither failed nor done
+	if statuses := reportStatuses(t, syncer); statuses["api-service"] != "skipped: interrupted" {
+		t.Errorf("api-service: expected to be reported as interrupted, got %q", statuses["api-service"])
+	}
+	checkpoint, err := state.LoadCheckpoint(checkpointPath, testOrg)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+	if checkpoint.IsDone("api-service") {
+		t.Error("api-service: interrupted repository must not be checkpointed as done")
+	}
+	webAppDone := checkpoint.IsDone("web-app")
+
+	// Resuming processes only what is left and removes the checkpoint
+	resumed := newSynchronizer(t, githubClient.NewClientFromGitHub(srv.NewClient(), testOrg), &options{})
+	resumed.checkpoint = checkpoint
+	if err := resumed.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+
+	if commits := srv.Commits("api-service"); len(commits) != 1 {
+		t.Errorf("api-service: expected 1 commit after resuming, got %d", len(commits))
+	}
+	if commits := srv.Commits("web-app"); len(commits) != 1 {
+		t.Errorf("web-app: expected exactly 1 commit across both runs, got %d", len(commits))
+	}
+	statuses := reportStatuses(t, resumed)
+	if _, ok := statuses["web-app"]; ok == webAppDone {
+		t.Errorf("web-app: expected to be processed on resume only if not done before, got %v", statuses)
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed after a complete run, got %v", err)
+	}
+}
+
+// checkpointingClient reads the checkpoint file whenever a repository is
+// about to be written, as a run killed at that point would leave it
+type checkpointingClient struct {
+	githubClient.API
+	path    string
+	written []string
+	seen    []*state.Checkpoint
+	err     error
+}
+
+func (c *checkpointingClient) CreateOrUpdateFile(ctx context.Context, repo, path, message string, content []byte, sha string) error {
+	checkpoint, err := state.LoadCheckpoint(c.path, testOrg)
+	if err != nil {
+		c.err = err
+	}
+	c.written = append(c.written, repo)
+	c.seen = append(c.seen, checkpoint)
+	return c.API.CreateOrUpdateFile(ctx, repo, path, message, content, sha)
+}
+
+func TestSynchronizer_Run_CheckpointSavedPeriodically(t *testing.T) {
+	srv, err := githubtest.NewServer(testOrg, "testdata/repos")
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	client := &checkpointingClient{
+		API:  githubClient.NewClientFromGitHub(srv.NewClient(), testOrg),
+		path: checkpointPath,
+	}
+	syncer := newSynchronizer(t, client, &options{checkpointEvery: 1})
+	syncer.options.concurrency = 1
+	syncer.checkpoint = state.NewCheckpoint(checkpointPath, testOrg)
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if client.err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", client.err)
+	}
+
+	// Repositories run one at a time, so by the second write the first
+	// written repository is finished and on disk
+	if len(client.seen) != 2 {
+		t.Fatalf("expected 2 repositories written, got %d", len(client.seen))
+	}
+	if !client.seen[1].IsDone(client.written[0]) {
+		t.Errorf("%s: expected to be checkpointed before the run ends", client.written[0])
+	}
+	if client.seen[1].IsDone(client.written[1]) {
+		t.Errorf("%s: must not be checkpointed before it is written", client.written[1])
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed after a complete run, got %v", err)
+	}
+}
+
+func TestSynchronizer_Run_TemplateVariants(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{})
+
+	// A variant of the Go template for repositories written in Go
+	configs := t.TempDir()
+	if err := os.CopyFS(configs, os.DirFS("../../configs")); err != nil {
+		t.Fatal(err)
+	}
+	variant := "ecosystem: gomod\nwhen:\n  language: go\nversion: 2\nupdates:\n- labels: [go-service]\n"
+	if err := os.WriteFile(filepath.Join(configs, "golang", "services.yml"), []byte(variant), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mrg, err := merger.New(configs)
+	if err != nil {
+		t.Fatalf("failed to initialize merger: %v", err)
+	}
+	syncer.merger = mrg
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	commits := srv.Commits("api-service")
+	if len(commits) != 1 {
+		t.Fatalf("api-service: expected 1 commit, got %d", len(commits))
+	}
+	cfg := parseConfig(t, commits[0].Content)
+	if len(cfg.Updates) != 1 || !slices.Contains(cfg.Updates[0].Labels, "go-service") {
+		t.Errorf("api-service: expected the Go services variant, got %+v", cfg.Updates)
+	}
+
+	templates := make(map[string][]string)
+	for _, detail := range saveReport(t, syncer).RepositoryDetails {
+		templates[detail.Name] = detail.Templates
+	}
+	if got := templates["api-service"]; !slices.Equal(got, []string{"golang/services"}) {
+		t.Errorf("api-service: expected golang/services in the report, got %v", got)
+	}
+	if got := templates["web-app"]; !slices.Contains(got, "npm/default") {
+		t.Errorf("web-app: expected npm/default in the report, got %v", got)
+	}
+}
+
+func TestSynchronizer_Run_TemplateVariables(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{})
+
+	// An override reviewing api-service with its CODEOWNERS team
+	configs := t.TempDir()
+	if err := os.CopyFS(configs, os.DirFS("../../configs")); err != nil {
+		t.Fatal(err)
+	}
+	override := "update:\n  reviewers: [\"{{ .Team }}\"]\n  target-branch: \"{{ .Repo.DefaultBranch }}\"\n"
+	if err := os.Mkdir(filepath.Join(configs, "repos"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configs, "repos", "api-service.yml"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mrg, err := merger.New(configs)
+	if err != nil {
+		t.Fatalf("failed to initialize merger: %v", err)
+	}
+	syncer.merger = mrg
+
+	if err := srv.PutFile("api-service", "develop", ".github/CODEOWNERS", []byte("* @acme/backend\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	commits := srv.Commits("api-service")
+	if len(commits) != 1 {
+		t.Fatalf("api-service: expected 1 commit, got %d", len(commits))
+	}
+	cfg := parseConfig(t, commits[0].Content)
+	if len(cfg.Updates) != 1 {
+		t.Fatalf("api-service: expected 1 update, got %+v", cfg.Updates)
+	}
+	if update := cfg.Updates[0]; !slices.Equal(update.Reviewers, []string{"acme/backend"}) || update.TargetBranch != "develop" {
+		t.Errorf("api-service: expected the variables expanded, got %+v", update)
+	}
+}
+
+func TestSynchronizer_Run_Prune(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{prune: true})
+
+	// api-service has no npm packages or Dockerfile
+	existing := []byte(`version: 2
+updates:
+  - package-ecosystem: "gomod"
+    directory: "/"
+    schedule:
+      interval: "monthly"
+  - package-ecosystem: "npm"
+    directory: "/frontend"
+    schedule:
+      interval: "weekly"
+  # dependabot-sync: keep
+  - package-ecosystem: "docker"
+    directory: "/"
+    schedule:
+      interval: "weekly"
+`)
+	if err := srv.PutFile("api-service", "develop", ".github/dependabot.yml", existing); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syncer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	commits := srv.Commits("api-service")
+	if len(commits) != 1 {
+		t.Fatalf("api-service: expected 1 commit, got %d", len(commits))
+	}
+	var ecosystems []string
+	for _, update := range parseConfig(t, commits[0].Content).Updates {
+		ecosystems = append(ecosystems, update.PackageEcosystem)
+	}
+	if !slices.Equal(ecosystems, []string{"gomod", "docker"}) {
+		t.Errorf("api-service: expected the npm update pruned, got %v", ecosystems)
+	}
+
+	for _, detail := range saveReport(t, syncer).RepositoryDetails {
+		if detail.Name == "api-service" && !slices.Equal(detail.Pruned, []string{"npm /frontend"}) {
+			t.Errorf("api-service: expected the pruned update in the report, got %v", detail.Pruned)
+		}
+	}
+}
+
+func TestSynchronizer_Run_CollapseAcrossRuns(t *testing.T) {
+	syncer, srv := newTestSynchronizer(t, &options{collapseDirs: true, prune: true})
+
+	// run syncs once and returns the npm update committed to web-app
+	run := func(name string) []config.DependabotUpdate {
+		t.Helper()
+
+		resetReporter(t, syncer)
+		if err := syncer.Run(context.Background()); err != nil {
+			t.Fatalf("%s: Run() error = %v", name, err)
+		}
+		content, ok := srv.File("web-app", "main", ".github/dependabot.yml")
+		if !ok {
+			t.Fatalf("%s: expected a configuration", name)
+		}
+
+		var npm []config.DependabotUpdate
+		for _, update := range parseConfig(t, content).Updates {
+			if update.PackageEcosystem == "npm" {
+				npm = append(npm, update)
+			}
+		}
+		if len(npm) != 1 {
+			t.Fatalf("%s: expected a single npm update, got %+v", name, npm)
+		}
+		// Rendering keeps the order of the existing list
+		slices.Sort(npm[0].Directories)
+		return npm
+	}
+
+	for _, path := range []string{"services/api/package.json", "services/web/package.json"} {
+		if err := srv.PutFile("web-app", "main", path, []byte("{}\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if npm := run("first run"); !slices.Equal(npm[0].Directories, []string{"/", "/services/*"}) {
+		t.Errorf("first run: expected directories / and /services/*, got %+v", npm[0])
+	}
+
+	// A new directory joins the collapsed update
+	if err := srv.PutFile("web-app", "main", "packages/ui/package.json", []byte("{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if npm := run("second run"); !slices.Equal(npm[0].Directories, []string{"/", "/packages/ui", "/services/*"}) {
+		t.Errorf("second run: expected directories /, /packages/ui and /services/*, got %+v", npm[0])
+	}
+
+	// A glob matching nothing anymore is pruned from it
+	for _, path := range []string{"services/api/package.json", "services/web/package.json"} {
+		if err := srv.RemoveFile("web-app", "main", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if npm := run("third run"); !slices.Equal(npm[0].Directories, []string{"/", "/packages/ui"}) {
+		t.Errorf("third run: expected directories / and /packages/ui, got %+v", npm[0])
+	}
+	if commits := srv.Commits("web-app"); len(commits) != 3 {
+		t.Errorf("expected a commit per run, got %d", len(commits))
+	}
+}
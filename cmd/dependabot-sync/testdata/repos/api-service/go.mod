@@ -0,0 +1,3 @@
+module example.com/api-service
+
+go 1.22
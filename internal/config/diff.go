@@ -0,0 +1,248 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Difference describes a single field that differs between two configs.
+// Old is nil when the value was added, New is nil when it was removed.
+type Difference struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// String formats the difference for display
+func (d Difference) String() string {
+	switch {
+	case d.Old == nil:
+		return fmt.Sprintf("%s: added %s", d.Path, formatValue(d.New))
+	case d.New == nil:
+		return fmt.Sprintf("%s: removed %s", d.Path, formatValue(d.Old))
+	default:
+		return fmt.Sprintf("%s: %s → %s", d.Path, formatValue(d.Old), formatValue(d.New))
+	}
+}
+
+// unorderedLists are the lists Dependabot treats as sets, keyed by field
+// name. Updates are matched by ecosystem, directory and target branch.
+var unorderedLists = map[string]bool{
+	"labels":           true,
+	"reviewers":        true,
+	"assignees":        true,
+	"directories":      true,
+	"exclude-paths":    true,
+	"patterns":         true,
+	"exclude-patterns": true,
+	"update-types":     true,
+	"versions":         true,
+	"registries":       true,
+	"include":          true,
+	"exclude":          true,
+	"ignore":           true,
+	"allow":            true,
+}
+
+// Diff lists the differences between c and other, field by field. Updates
+// and set-like lists are compared without regard to their order.
+func (c *DependabotConfig) Diff(other *DependabotConfig) []Difference {
+	if c == nil && other == nil {
+		return nil
+	}
+	if c == nil || other == nil {
+		return []Difference{{Path: "config", Old: normalize(c), New: normalize(other)}}
+	}
+
+	var diffs []Difference
+	diffValues("", "", normalize(c), normalize(other), &diffs)
+	return diffs
+}
+
+// Diff lists the differences between two updates
+func (u *DependabotUpdate) Diff(other *DependabotUpdate) []Difference {
+	var diffs []Difference
+	diffValues("", "", normalize(u), normalize(other), &diffs)
+	return diffs
+}
+
+// normalize converts a config value into the generic form it has in YAML,
+// so every field, including unknown ones, is compared the same way
+func normalize(v interface{}) interface{} {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// diffValues compares two normalized values found under key at path
+func diffValues(path, key string, a, b interface{}, diffs *[]Difference) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+
+	switch {
+	case a == nil:
+		*diffs = append(*diffs, Difference{Path: path, New: b})
+		return
+	case b == nil:
+		*diffs = append(*diffs, Difference{Path: path, Old: a})
+		return
+	}
+
+	am, aIsMap := a.(map[string]interface{})
+	bm, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		diffMaps(path, am, bm, diffs)
+		return
+	}
+
+	al, aIsList := a.([]interface{})
+	bl, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		switch {
+		case key == "updates":
+			diffUpdates(path, al, bl, diffs)
+			return
+		case unorderedLists[key]:
+			diffSets(path, al, bl, diffs)
+			return
+		}
+	}
+
+	*diffs = append(*diffs, Difference{Path: path, Old: a, New: b})
+}
+
+// diffMaps compares two mappings key by key in sorted order
+func diffMaps(path string, a, b map[string]interface{}, diffs *[]Difference) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		diffValues(joinPath(path, k), k, a[k], b[k], diffs)
+	}
+}
+
+// diffUpdates pairs updates by identity and compares each pair
+func diffUpdates(path string, a, b []interface{}, diffs *[]Difference) {
+	aByID, aOrder := indexUpdates(a)
+	bByID, bOrder := indexUpdates(b)
+
+	for _, id := range aOrder {
+		diffValues(fmt.Sprintf("%s[%s]", path, id), "", aByID[id], bByID[id], diffs)
+	}
+	for _, id := range bOrder {
+		if _, ok := aByID[id]; !ok {
+			diffValues(fmt.Sprintf("%s[%s]", path, id), "", nil, bByID[id], diffs)
+		}
+	}
+}
+
+// indexUpdates keys updates by their identity, keeping the first-seen order
+func indexUpdates(updates []interface{}) (map[string]interface{}, []string) {
+	byID := make(map[string]interface{}, len(updates))
+	var order []string
+
+	for _, update := range updates {
+		id := updateID(update)
+		// Keep duplicates apart so they are still compared
+		for n := 2; byID[id] != nil; n++ {
+			id = fmt.Sprintf("%s#%d", updateID(update), n)
+		}
+		byID[id] = update
+		order = append(order, id)
+	}
+
+	return byID, order
+}
+
+// updateID identifies an update the way Dependabot does: by ecosystem,
+// directory and target branch
+func updateID(update interface{}) string {
+	m, _ := update.(map[string]interface{})
+
+	id := fmt.Sprint(m["package-ecosystem"])
+	switch {
+	case m["directory"] != nil:
+		id += " " + fmt.Sprint(m["directory"])
+	case m["directories"] != nil:
+		id += " " + formatValue(m["directories"])
+	}
+	if branch, ok := m["target-branch"]; ok {
+		id += "@" + fmt.Sprint(branch)
+	}
+
+	return id
+}
+
+// diffSets reports the items only present in one of two unordered lists
+func diffSets(path string, a, b []interface{}, diffs *[]Difference) {
+	count := make(map[string]int)
+	for _, item := range b {
+		count[formatValue(item)]++
+	}
+
+	var removed []interface{}
+	for _, item := range a {
+		key := formatValue(item)
+		if count[key] > 0 {
+			count[key]--
+			continue
+		}
+		removed = append(removed, item)
+	}
+
+	for _, item := range removed {
+		*diffs = append(*diffs, Difference{Path: path, Old: item})
+	}
+
+	for _, item := range b {
+		key := formatValue(item)
+		if count[key] > 0 {
+			count[key]--
+			*diffs = append(*diffs, Difference{Path: path, New: item})
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// formatValue renders a value compactly; scalars as-is, others as JSON
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
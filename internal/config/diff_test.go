@@ -0,0 +1,126 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependabotConfig_Diff(t *testing.T) {
+	npm := DependabotUpdate{
+		PackageEcosystem: "npm",
+		Directory:        "/",
+		Schedule:         Schedule{Interval: "weekly"},
+		Labels:           []string{"dependencies", "javascript"},
+		Ignore: []IgnoreConfig{
+			{DependencyName: "react", UpdateTypes: []string{"version-update:semver-major"}},
+			{DependencyName: "lodash"},
+		},
+	}
+	docker := DependabotUpdate{
+		PackageEcosystem: "docker",
+		Directory:        "/",
+		Schedule:         Schedule{Interval: "monthly"},
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(c *DependabotConfig)
+		expected []string
+	}{
+		{
+			name:   "identical",
+			modify: func(c *DependabotConfig) {},
+		},
+		{
+			name: "reordered updates",
+			modify: func(c *DependabotConfig) {
+				c.Updates[0], c.Updates[1] = c.Updates[1], c.Updates[0]
+			},
+		},
+		{
+			name: "reordered labels and ignore rules",
+			modify: func(c *DependabotConfig) {
+				c.Updates[0].Labels = []string{"javascript", "dependencies"}
+				c.Updates[0].Ignore = []IgnoreConfig{c.Updates[0].Ignore[1], c.Updates[0].Ignore[0]}
+			},
+		},
+		{
+			name: "changed schedule",
+			modify: func(c *DependabotConfig) {
+				c.Updates[1].Schedule.Interval = "daily"
+			},
+			expected: []string{"updates[docker /].schedule.interval: monthly → daily"},
+		},
+		{
+			name: "changed labels",
+			modify: func(c *DependabotConfig) {
+				c.Updates[0].Labels = []string{"dependencies", "frontend"}
+			},
+			expected: []string{
+				"updates[npm /].labels: removed javascript",
+				"updates[npm /].labels: added frontend",
+			},
+		},
+		{
+			name: "added group and commit message",
+			modify: func(c *DependabotConfig) {
+				c.Updates[1].Groups = map[string]GroupConfig{"all": {Patterns: []string{"*"}}}
+				c.Updates[1].CommitMessage = &CommitMessage{Prefix: "chore"}
+			},
+			expected: []string{
+				`updates[docker /].commit-message: added {"prefix":"chore"}`,
+				`updates[docker /].groups: added {"all":{"patterns":["*"]}}`,
+			},
+		},
+		{
+			name: "changed ignore rule",
+			modify: func(c *DependabotConfig) {
+				c.Updates[0].Ignore = c.Updates[0].Ignore[:1]
+			},
+			expected: []string{`updates[npm /].ignore: removed {"dependency-name":"lodash"}`},
+		},
+		{
+			name: "removed update and unknown key",
+			modify: func(c *DependabotConfig) {
+				c.Updates = c.Updates[:1]
+				c.Extra = map[string]interface{}{"x-owner": "platform"}
+			},
+			expected: []string{
+				"updates[docker /]: removed " + `{"directory":"/","package-ecosystem":"docker","schedule":{"interval":"monthly"}}`,
+				"x-owner: added platform",
+			},
+		},
+		{
+			name: "update for another target branch",
+			modify: func(c *DependabotConfig) {
+				release := c.Updates[1]
+				release.TargetBranch = "release"
+				c.Updates = append(c.Updates, release)
+			},
+			expected: []string{
+				"updates[docker /@release]: added " + `{"directory":"/","package-ecosystem":"docker","schedule":{"interval":"monthly"},"target-branch":"release"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &DependabotConfig{Version: 2, Updates: []DependabotUpdate{npm, docker}}
+			other := &DependabotConfig{Version: 2, Updates: []DependabotUpdate{npm, docker}}
+			other.Updates[0].Labels = append([]string(nil), npm.Labels...)
+			other.Updates[0].Ignore = append([]IgnoreConfig(nil), npm.Ignore...)
+			tt.modify(other)
+
+			var got []string
+			for _, d := range base.Diff(other) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Diff() = %q, want %q", got, tt.expected)
+			}
+			if equal := base.Equal(other); equal != (len(tt.expected) == 0) {
+				t.Errorf("Equal() = %v, want %v", equal, len(tt.expected) == 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+// ecosystems are the package-ecosystem values Dependabot accepts
+var ecosystems = map[string]bool{
+	"bun":            true,
+	"bundler":        true,
+	"cargo":          true,
+	"composer":       true,
+	"conda":          true,
+	"devcontainers":  true,
+	"docker":         true,
+	"docker-compose": true,
+	"dotnet-sdk":     true,
+	"elm":            true,
+	"gitsubmodule":   true,
+	"github-actions": true,
+	"gomod":          true,
+	"gradle":         true,
+	"helm":           true,
+	"maven":          true,
+	"mix":            true,
+	"npm":            true,
+	"nuget":          true,
+	"pip":            true,
+	"pub":            true,
+	"swift":          true,
+	"terraform":      true,
+	"uv":             true,
+	"vcpkg":          true,
+}
+
+// IsEcosystem reports whether name is a package ecosystem Dependabot
+// supports
+func IsEcosystem(name string) bool {
+	return ecosystems[name]
+}
@@ -1,18 +1,30 @@
 // Package config defines the types and structures for Dependabot configuration management.
 package config
 
+import "gopkg.in/yaml.v3"
+
 // DependabotConfig represents the Dependabot configuration
 type DependabotConfig struct {
-	Version int                `yaml:"version"`
-	Updates []DependabotUpdate `yaml:"updates"`
+	Version              int                            `yaml:"version"`
+	EnableBetaEcosystems bool                           `yaml:"enable-beta-ecosystems,omitempty"`
+	Registries           map[string]Registry            `yaml:"registries,omitempty"`
+	MultiEcosystemGroups map[string]MultiEcosystemGroup `yaml:"multi-ecosystem-groups,omitempty"`
+	Updates              []DependabotUpdate             `yaml:"updates"`
+	// Extra keeps keys this tool does not know about so they survive a rewrite
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
 // DependabotUpdate represents an update configuration
 type DependabotUpdate struct {
 	PackageEcosystem      string                 `yaml:"package-ecosystem"`
-	Directory             string                 `yaml:"directory"`
+	Directory             string                 `yaml:"directory,omitempty"`
+	Directories           []string               `yaml:"directories,omitempty"`
+	ExcludePaths          []string               `yaml:"exclude-paths,omitempty"`
+	MultiEcosystemGroup   string                 `yaml:"multi-ecosystem-group,omitempty"`
+	Patterns              []string               `yaml:"patterns,omitempty"`
 	Schedule              Schedule               `yaml:"schedule"`
-	OpenPullRequestsLimit int                    `yaml:"open-pull-requests-limit,omitempty"`
+	Cooldown              *Cooldown              `yaml:"cooldown,omitempty"`
+	OpenPullRequestsLimit *int                   `yaml:"open-pull-requests-limit,omitempty"`
 	Labels                []string               `yaml:"labels,omitempty"`
 	Reviewers             []string               `yaml:"reviewers,omitempty"`
 	Assignees             []string               `yaml:"assignees,omitempty"`
@@ -20,89 +32,154 @@ type DependabotUpdate struct {
 	Groups                map[string]GroupConfig `yaml:"groups,omitempty"`
 	VersioningStrategy    string                 `yaml:"versioning-strategy,omitempty"`
 	CommitMessage         *CommitMessage         `yaml:"commit-message,omitempty"`
+	PullRequestBranchName *PullRequestBranchName `yaml:"pull-request-branch-name,omitempty"`
 	TargetBranch          string                 `yaml:"target-branch,omitempty"`
-	Vendor                bool                   `yaml:"vendor,omitempty"`
-	Insecure              []string               `yaml:"insecure-external-code-execution,omitempty"`
+	Vendor                *bool                  `yaml:"vendor,omitempty"`
+	Insecure              string                 `yaml:"insecure-external-code-execution,omitempty"`
 	RebaseStrategy        string                 `yaml:"rebase-strategy,omitempty"`
 	Ignore                []IgnoreConfig         `yaml:"ignore,omitempty"`
 	Allow                 []AllowConfig          `yaml:"allow,omitempty"`
-	Registries            []string               `yaml:"registries,omitempty"`
+	Registries            RegistryList           `yaml:"registries,omitempty"`
+	Extra                 map[string]interface{} `yaml:",inline"`
 }
 
 // Schedule represents update schedule
 type Schedule struct {
-	Interval string `yaml:"interval"`
-	Day      string `yaml:"day,omitempty"`
-	Time     string `yaml:"time,omitempty"`
-	Timezone string `yaml:"timezone,omitempty"`
+	Interval string                 `yaml:"interval"`
+	Day      string                 `yaml:"day,omitempty"`
+	Time     string                 `yaml:"time,omitempty"`
+	Timezone string                 `yaml:"timezone,omitempty"`
+	Cronjob  string                 `yaml:"cronjob,omitempty"`
+	Extra    map[string]interface{} `yaml:",inline"`
+}
+
+// Cooldown delays version updates until a release has aged
+type Cooldown struct {
+	DefaultDays     int                    `yaml:"default-days,omitempty"`
+	SemverMajorDays int                    `yaml:"semver-major-days,omitempty"`
+	SemverMinorDays int                    `yaml:"semver-minor-days,omitempty"`
+	SemverPatchDays int                    `yaml:"semver-patch-days,omitempty"`
+	Include         []string               `yaml:"include,omitempty"`
+	Exclude         []string               `yaml:"exclude,omitempty"`
+	Extra           map[string]interface{} `yaml:",inline"`
 }
 
 // GroupConfig represents dependency grouping
 type GroupConfig struct {
-	DependencyType  string   `yaml:"dependency-type,omitempty"`
-	Patterns        []string `yaml:"patterns,omitempty"`
-	ExcludePatterns []string `yaml:"exclude-patterns,omitempty"`
-	UpdateTypes     []string `yaml:"update-types,omitempty"`
+	AppliesTo       string                 `yaml:"applies-to,omitempty"`
+	DependencyType  string                 `yaml:"dependency-type,omitempty"`
+	Patterns        []string               `yaml:"patterns,omitempty"`
+	ExcludePatterns []string               `yaml:"exclude-patterns,omitempty"`
+	UpdateTypes     []string               `yaml:"update-types,omitempty"`
+	GroupBy         string                 `yaml:"group-by,omitempty"`
+	Extra           map[string]interface{} `yaml:",inline"`
+}
+
+// MultiEcosystemGroup combines updates of several ecosystems into one pull
+// request. Updates join it through their multi-ecosystem-group key.
+type MultiEcosystemGroup struct {
+	Schedule              Schedule               `yaml:"schedule"`
+	Labels                []string               `yaml:"labels,omitempty"`
+	Assignees             []string               `yaml:"assignees,omitempty"`
+	Milestone             int                    `yaml:"milestone,omitempty"`
+	TargetBranch          string                 `yaml:"target-branch,omitempty"`
+	CommitMessage         *CommitMessage         `yaml:"commit-message,omitempty"`
+	PullRequestBranchName *PullRequestBranchName `yaml:"pull-request-branch-name,omitempty"`
+	Extra                 map[string]interface{} `yaml:",inline"`
 }
 
 // CommitMessage represents commit message configuration
 type CommitMessage struct {
-	Prefix            string `yaml:"prefix,omitempty"`
-	PrefixDevelopment string `yaml:"prefix-development,omitempty"`
-	Include           string `yaml:"include,omitempty"`
+	Prefix            string                 `yaml:"prefix,omitempty"`
+	PrefixDevelopment string                 `yaml:"prefix-development,omitempty"`
+	Include           string                 `yaml:"include,omitempty"`
+	Extra             map[string]interface{} `yaml:",inline"`
+}
+
+// PullRequestBranchName configures the branch names Dependabot creates
+type PullRequestBranchName struct {
+	Separator string                 `yaml:"separator,omitempty"`
+	Extra     map[string]interface{} `yaml:",inline"`
 }
 
 // IgnoreConfig represents dependency ignore rules
 type IgnoreConfig struct {
-	DependencyName string   `yaml:"dependency-name,omitempty"`
-	Versions       []string `yaml:"versions,omitempty"`
-	UpdateTypes    []string `yaml:"update-types,omitempty"`
+	DependencyName string                 `yaml:"dependency-name,omitempty"`
+	Versions       []string               `yaml:"versions,omitempty"`
+	UpdateTypes    []string               `yaml:"update-types,omitempty"`
+	Extra          map[string]interface{} `yaml:",inline"`
 }
 
 // AllowConfig represents dependency allow rules
 type AllowConfig struct {
-	DependencyName string `yaml:"dependency-name,omitempty"`
-	DependencyType string `yaml:"dependency-type,omitempty"`
+	DependencyName string                 `yaml:"dependency-name,omitempty"`
+	DependencyType string                 `yaml:"dependency-type,omitempty"`
+	Extra          map[string]interface{} `yaml:",inline"`
 }
 
-// Equal checks if two configs are equal
-func (c *DependabotConfig) Equal(other *DependabotConfig) bool {
-	if c == nil && other == nil {
-		return true
-	}
-	if c == nil || other == nil {
-		return false
-	}
-	if c.Version != other.Version {
-		return false
+// Registry represents a private registry Dependabot can authenticate to
+type Registry struct {
+	Type                 string                 `yaml:"type"`
+	URL                  string                 `yaml:"url,omitempty"`
+	Username             string                 `yaml:"username,omitempty"`
+	Password             string                 `yaml:"password,omitempty"`
+	Key                  string                 `yaml:"key,omitempty"`
+	Token                string                 `yaml:"token,omitempty"`
+	ReplacesBase         *bool                  `yaml:"replaces-base,omitempty"`
+	Organization         string                 `yaml:"organization,omitempty"`
+	Repo                 string                 `yaml:"repo,omitempty"`
+	AuthKey              string                 `yaml:"auth-key,omitempty"`
+	PublicKeyFingerprint string                 `yaml:"public-key-fingerprint,omitempty"`
+	Extra                map[string]interface{} `yaml:",inline"`
+}
+
+// AllRegistries allows an update to use every configured registry
+const AllRegistries = "*"
+
+// RegistryList lists the registries an update may use. Dependabot accepts
+// either a list of names or "*" for all registries.
+type RegistryList []string
+
+// UnmarshalYAML accepts a single name as well as a list
+func (r *RegistryList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*r = RegistryList{value.Value}
+		return nil
 	}
-	if len(c.Updates) != len(other.Updates) {
-		return false
+
+	var names []string
+	if err := value.Decode(&names); err != nil {
+		return err
 	}
+	*r = names
+	return nil
+}
 
-	// Simple comparison - in production would need deep comparison
-	for i := range c.Updates {
-		if !c.Updates[i].Equal(&other.Updates[i]) {
-			return false
-		}
+// MarshalYAML writes the "*" wildcard back as a scalar
+func (r RegistryList) MarshalYAML() (interface{}, error) {
+	if len(r) == 1 && r[0] == AllRegistries {
+		return AllRegistries, nil
 	}
-	return true
+	return []string(r), nil
+}
+
+// Int returns a pointer to v, for optional integer settings
+func Int(v int) *int {
+	return &v
+}
+
+// Bool returns a pointer to v, for optional boolean settings
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Equal checks if two configs are equal, ignoring the order of updates and
+// of set-like lists such as labels
+func (c *DependabotConfig) Equal(other *DependabotConfig) bool {
+	return len(c.Diff(other)) == 0
 }
 
 // Equal checks if two updates are equal
 func (u *DependabotUpdate) Equal(other *DependabotUpdate) bool {
-	if u.PackageEcosystem != other.PackageEcosystem {
-		return false
-	}
-	if u.Directory != other.Directory {
-		return false
-	}
-	if u.Schedule.Interval != other.Schedule.Interval {
-		return false
-	}
-	if u.OpenPullRequestsLimit != other.OpenPullRequestsLimit {
-		return false
-	}
-	// Additional comparisons would be needed for production
-	return true
+	return len(u.Diff(other)) == 0
 }
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"bytes"
+	"reflect"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestDependabotConfig_Equal(t *testing.T) {
@@ -113,13 +118,13 @@ func TestDependabotUpdate_Equal(t *testing.T) {
 				PackageEcosystem:      "npm",
 				Directory:             "/",
 				Schedule:              Schedule{Interval: "weekly"},
-				OpenPullRequestsLimit: 10,
+				OpenPullRequestsLimit: Int(10),
 			},
 			update2: DependabotUpdate{
 				PackageEcosystem:      "npm",
 				Directory:             "/",
 				Schedule:              Schedule{Interval: "weekly"},
-				OpenPullRequestsLimit: 10,
+				OpenPullRequestsLimit: Int(10),
 			},
 			expected: true,
 		},
@@ -166,15 +171,43 @@ func TestDependabotUpdate_Equal(t *testing.T) {
 			update1: DependabotUpdate{
 				PackageEcosystem:      "npm",
 				Directory:             "/",
-				OpenPullRequestsLimit: 5,
+				OpenPullRequestsLimit: Int(5),
 			},
 			update2: DependabotUpdate{
 				PackageEcosystem:      "npm",
 				Directory:             "/",
-				OpenPullRequestsLimit: 10,
+				OpenPullRequestsLimit: Int(10),
 			},
 			expected: false,
 		},
+		{
+			name: "different labels",
+			update1: DependabotUpdate{
+				PackageEcosystem: "npm",
+				Directory:        "/",
+				Labels:           []string{"dependencies"},
+			},
+			update2: DependabotUpdate{
+				PackageEcosystem: "npm",
+				Directory:        "/",
+				Labels:           []string{"dependencies", "javascript"},
+			},
+			expected: false,
+		},
+		{
+			name: "reordered labels",
+			update1: DependabotUpdate{
+				PackageEcosystem: "npm",
+				Directory:        "/",
+				Labels:           []string{"javascript", "dependencies"},
+			},
+			update2: DependabotUpdate{
+				PackageEcosystem: "npm",
+				Directory:        "/",
+				Labels:           []string{"dependencies", "javascript"},
+			},
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -185,3 +218,103 @@ func TestDependabotUpdate_Equal(t *testing.T) {
 		})
 	}
 }
+
+func TestDependabotConfig_RoundTrip(t *testing.T) {
+	input := `version: 2
+enable-beta-ecosystems: true
+registries:
+    npm-github:
+        type: npm-registry
+        url: https://npm.pkg.github.com
+        token: ${{ secrets.NPM_TOKEN }}
+        replaces-base: true
+        x-custom-registry-key: kept
+multi-ecosystem-groups:
+    infrastructure:
+        schedule:
+            interval: weekly
+        labels:
+            - infra
+        commit-message:
+            prefix: infra
+        pull-request-branch-name:
+            separator: '-'
+updates:
+    - package-ecosystem: npm
+      directories:
+        - /
+        - /packages/*
+      exclude-paths:
+        - vendor/**
+      schedule:
+        interval: cron
+        cronjob: 0 4 * * 1
+      cooldown:
+        default-days: 5
+        semver-major-days: 30
+        include:
+            - '*'
+      open-pull-requests-limit: 0
+      groups:
+        production:
+            applies-to: security-updates
+            group-by: dependency-name
+            patterns:
+                - '*'
+      pull-request-branch-name:
+        separator: /
+      target-branch: develop
+      vendor: false
+      insecure-external-code-execution: deny
+      registries: '*'
+      x-unknown-update-key:
+        nested: true
+    - package-ecosystem: docker
+      directory: /
+      multi-ecosystem-group: infrastructure
+      patterns:
+        - '*'
+      schedule:
+        interval: weekly
+      registries:
+        - npm-github
+x-unknown-top-level: 1
+`
+
+	var cfg DependabotConfig
+	if err := yaml.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if got := cfg.Updates[0].Registries; len(got) != 1 || got[0] != AllRegistries {
+		t.Errorf("registries wildcard not decoded, got %v", got)
+	}
+	if got := cfg.Updates[0].OpenPullRequestsLimit; got == nil || *got != 0 {
+		t.Errorf("explicit zero PR limit not decoded, got %v", got)
+	}
+	if got := cfg.MultiEcosystemGroups["infrastructure"].CommitMessage; got == nil || got.Prefix != "infra" {
+		t.Errorf("multi-ecosystem group commit-message not decoded, got %+v", got)
+	}
+
+	// Re-encoding must yield the same document, including unknown keys
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(4)
+	if err := encoder.Encode(&cfg); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	var want, got interface{}
+	if err := yaml.Unmarshal([]byte(input), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round trip lost data, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "registries: '*'\n") {
+		t.Errorf("registries wildcard should be written as a scalar, got:\n%s", buf.String())
+	}
+}
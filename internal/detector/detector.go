@@ -3,7 +3,6 @@ package detector
 
 import (
 	"context"
-	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -18,134 +17,183 @@ type Ecosystem struct {
 	Confidence  float64
 }
 
+// TreeSource lists the files on the default branch of a repository
+type TreeSource interface {
+	GetTree(ctx context.Context, repo string) ([]string, error)
+}
+
 // Detector detects package ecosystems in a repository
 type Detector struct {
-	client *github.Client
-	org    string
+	source TreeSource
 }
 
 // New creates a new ecosystem detector
-func New(client *github.Client, org string) *Detector {
+func New(source TreeSource) *Detector {
 	return &Detector{
-		client: client,
-		org:    org,
+		source: source,
 	}
 }
 
-// Detect analyzes repository files to identify ecosystems
+// indicators maps each ecosystem to the files that signal its presence
+var indicators = map[string][]indicator{
+	"npm": {
+		{file: "package-lock.json", confidence: 1.0},
+		{file: "yarn.lock", confidence: 1.0},
+		{file: "pnpm-lock.yaml", confidence: 1.0},
+		{file: "package.json", confidence: 0.8},
+	},
+	"gomod": {
+		{file: "go.sum", confidence: 1.0},
+		{file: "go.mod", confidence: 0.9},
+	},
+	"pip": {
+		{file: "poetry.lock", confidence: 1.0},
+		{file: "Pipfile.lock", confidence: 1.0},
+		{file: "requirements.txt", confidence: 0.8},
+		{file: "setup.py", confidence: 0.7},
+		{file: "pyproject.toml", confidence: 0.9},
+	},
+	"docker": {
+		{file: "Dockerfile", confidence: 0.9},
+		{file: "docker-compose.yml", confidence: 0.8},
+		{file: "docker-compose.yaml", confidence: 0.8},
+		{file: "Dockerfile.*", confidence: 0.9},
+	},
+	"maven": {
+		{file: "pom.xml", confidence: 0.9},
+	},
+	"gradle": {
+		{file: "gradle.lock", confidence: 1.0},
+		{file: "build.gradle", confidence: 0.8},
+		{file: "build.gradle.kts", confidence: 0.8},
+	},
+	"bundler": {
+		{file: "Gemfile.lock", confidence: 1.0},
+		{file: "Gemfile", confidence: 0.8},
+	},
+	"cargo": {
+		{file: "Cargo.lock", confidence: 1.0},
+		{file: "Cargo.toml", confidence: 0.8},
+	},
+	"composer": {
+		{file: "composer.lock", confidence: 1.0},
+		{file: "composer.json", confidence: 0.8},
+	},
+	"nuget": {
+		{file: "packages.config", confidence: 0.8},
+		{file: "*.csproj", confidence: 0.7},
+		{file: "*.fsproj", confidence: 0.7},
+		{file: "*.vbproj", confidence: 0.7},
+	},
+	"github-actions": {
+		{file: ".github/workflows/*.yml", confidence: 0.9},
+		{file: ".github/workflows/*.yaml", confidence: 0.9},
+	},
+	"terraform": {
+		{file: "*.tf", confidence: 0.8},
+		{file: ".terraform.lock.hcl", confidence: 1.0},
+	},
+	"elm": {
+		{file: "elm.json", confidence: 0.9},
+		{file: "elm-package.json", confidence: 0.8},
+	},
+	"gitsubmodule": {
+		{file: ".gitmodules", confidence: 0.9},
+	},
+	"pub": {
+		{file: "pubspec.yaml", confidence: 0.9},
+		{file: "pubspec.lock", confidence: 1.0},
+	},
+	"mix": {
+		{file: "mix.exs", confidence: 0.9},
+		{file: "mix.lock", confidence: 1.0},
+	},
+}
+
+// Detects reports whether the detector can find ecosystem in a repository
+func Detects(ecosystem string) bool {
+	_, ok := indicators[ecosystem]
+	return ok
+}
+
+// Detect analyzes repository files to identify ecosystems. When the source
+// can read files, members of workspaces are left to the workspace root.
 func (d *Detector) Detect(ctx context.Context, repo string) ([]Ecosystem, error) {
-	tree, _, err := d.client.Git.GetTree(ctx, d.org, repo, "HEAD", true)
+	paths, err := d.source.GetTree(ctx, repo)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get tree: %w", err)
+		return nil, err
 	}
 
-	ecosystems := make(map[string]*Ecosystem)
+	ecosystems := DetectPaths(paths)
+	if files, ok := d.source.(FileSource); ok {
+		return resolveWorkspaces(ctx, files, repo, paths, ecosystems)
+	}
+	return ecosystems, nil
+}
+
+// workspaceMarkers are root files of monorepo tools whose packages live in
+// subdirectories
+var workspaceMarkers = []string{"pnpm-workspace.yaml", "lerna.json", "nx.json", "turbo.json", "rush.json", "go.work"}
 
-	indicators := map[string][]indicator{
-		"npm": {
-			{file: "package-lock.json", confidence: 1.0},
-			{file: "yarn.lock", confidence: 1.0},
-			{file: "pnpm-lock.yaml", confidence: 1.0},
-			{file: "package.json", confidence: 0.8},
-		},
-		"gomod": {
-			{file: "go.sum", confidence: 1.0},
-			{file: "go.mod", confidence: 0.9},
-		},
-		"pip": {
-			{file: "poetry.lock", confidence: 1.0},
-			{file: "Pipfile.lock", confidence: 1.0},
-			{file: "requirements.txt", confidence: 0.8},
-			{file: "setup.py", confidence: 0.7},
-			{file: "pyproject.toml", confidence: 0.9},
-		},
-		"docker": {
-			{file: "Dockerfile", confidence: 0.9},
-			{file: "docker-compose.yml", confidence: 0.8},
-			{file: "docker-compose.yaml", confidence: 0.8},
-			{file: "Dockerfile.*", confidence: 0.9},
-		},
-		"maven": {
-			{file: "pom.xml", confidence: 0.9},
-		},
-		"gradle": {
-			{file: "gradle.lock", confidence: 1.0},
-			{file: "build.gradle", confidence: 0.8},
-			{file: "build.gradle.kts", confidence: 0.8},
-		},
-		"bundler": {
-			{file: "Gemfile.lock", confidence: 1.0},
-			{file: "Gemfile", confidence: 0.8},
-		},
-		"cargo": {
-			{file: "Cargo.lock", confidence: 1.0},
-			{file: "Cargo.toml", confidence: 0.8},
-		},
-		"composer": {
-			{file: "composer.lock", confidence: 1.0},
-			{file: "composer.json", confidence: 0.8},
-		},
-		"nuget": {
-			{file: "packages.config", confidence: 0.8},
-			{file: "*.csproj", confidence: 0.7},
-			{file: "*.fsproj", confidence: 0.7},
-			{file: "*.vbproj", confidence: 0.7},
-		},
-		"github-actions": {
-			{file: ".github/workflows/*.yml", confidence: 0.9},
-			{file: ".github/workflows/*.yaml", confidence: 0.9},
-		},
-		"terraform": {
-			{file: "*.tf", confidence: 0.8},
-			{file: ".terraform.lock.hcl", confidence: 1.0},
-		},
-		"elm": {
-			{file: "elm.json", confidence: 0.9},
-			{file: "elm-package.json", confidence: 0.8},
-		},
-		"gitsubmodule": {
-			{file: ".gitmodules", confidence: 0.9},
-		},
-		"pub": {
-			{file: "pubspec.yaml", confidence: 0.9},
-			{file: "pubspec.lock", confidence: 1.0},
-		},
-		"hex": {
-			{file: "mix.exs", confidence: 0.9},
-			{file: "mix.lock", confidence: 1.0},
-		},
+// DetectRoot identifies ecosystems from the files at the root of a
+// repository and in .github/workflows, as listed by a repository snapshot.
+// complete tells whether paths are all files of the repository. When they
+// are not, or a workspace is declared, the full tree is scanned instead.
+func (d *Detector) DetectRoot(ctx context.Context, repo string, paths []string, complete bool) ([]Ecosystem, error) {
+	if needsFullScan(paths, complete) {
+		return d.Detect(ctx, repo)
 	}
+	return DetectPaths(paths), nil
+}
 
-	for _, entry := range tree.Entries {
-		if entry.Type != nil && *entry.Type == "blob" && entry.Path != nil {
-			path := *entry.Path
-			dir := extractDirectory(path)
-
-			for ecosystem, files := range indicators {
-				for _, ind := range files {
-					if matchesPattern(path, ind.file) {
-						if _, exists := ecosystems[ecosystem]; !exists {
-							ecosystems[ecosystem] = &Ecosystem{
-								Name:        ecosystem,
-								Type:        ecosystem,
-								Directories: []string{},
-								Confidence:  ind.confidence,
-							}
-						} else if ind.confidence > ecosystems[ecosystem].Confidence {
-							ecosystems[ecosystem].Confidence = ind.confidence
-						}
+// needsFullScan reports whether root-level paths are not enough to detect
+// the ecosystems of a repository
+func needsFullScan(paths []string, complete bool) bool {
+	if !complete {
+		return true
+	}
 
-						// Some ecosystems always scan from root directory
-						directory := dir
-						switch ecosystem {
-						case "docker", "github-actions", "terraform", "gitsubmodule":
-							directory = "/"
+	for _, path := range paths {
+		for _, marker := range workspaceMarkers {
+			if path == marker {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DetectPaths identifies ecosystems from a list of repository file paths
+func DetectPaths(paths []string) []Ecosystem {
+	ecosystems := make(map[string]*Ecosystem)
+
+	for _, path := range paths {
+		dir := extractDirectory(path)
+
+		for ecosystem, files := range indicators {
+			for _, ind := range files {
+				if matchesPattern(path, ind.file) {
+					if _, exists := ecosystems[ecosystem]; !exists {
+						ecosystems[ecosystem] = &Ecosystem{
+							Name:        ecosystem,
+							Type:        ecosystem,
+							Directories: []string{},
+							Confidence:  ind.confidence,
 						}
+					} else if ind.confidence > ecosystems[ecosystem].Confidence {
+						ecosystems[ecosystem].Confidence = ind.confidence
+					}
 
-						ecosystems[ecosystem].Directories = appendUnique(
-							ecosystems[ecosystem].Directories, directory,
-						)
+					// Some ecosystems always scan from root directory
+					directory := dir
+					switch ecosystem {
+					case "docker", "github-actions", "terraform", "gitsubmodule":
+						directory = "/"
 					}
+
+					ecosystems[ecosystem].Directories = appendUnique(
+						ecosystems[ecosystem].Directories, directory,
+					)
 				}
 			}
 		}
@@ -165,7 +213,7 @@ func (d *Detector) Detect(ctx context.Context, repo string) ([]Ecosystem, error)
 		}
 	}
 
-	return result, nil
+	return result
 }
 
 // HasExclusionTopic checks if repository has exclusion topics
@@ -181,3 +181,122 @@ func TestDetector_HasExclusionTopic(t *testing.T) {
 		})
 	}
 }
+
+func TestDetector_DetectPaths(t *testing.T) {
+	paths := []string{
+		"package.json",
+		"package-lock.json",
+		"frontend/package.json",
+		"services/api/go.mod",
+		"services/api/go.sum",
+		"deploy/Dockerfile",
+		"apps/billing/mix.exs",
+		"apps/billing/mix.lock",
+		".github/workflows/ci.yml",
+		"README.md",
+	}
+
+	expected := map[string][]string{
+		"npm":            {"/", "/frontend"},
+		"gomod":          {"/services/api"},
+		"docker":         {"/"},
+		"mix":            {"/apps/billing"},
+		"github-actions": {"/"},
+	}
+
+	got := DetectPaths(paths)
+	if len(got) != len(expected) {
+		t.Fatalf("DetectPaths() found %d ecosystems, want %d", len(got), len(expected))
+	}
+
+	for _, eco := range got {
+		dirs, ok := expected[eco.Name]
+		if !ok {
+			t.Errorf("DetectPaths() found unexpected ecosystem %q", eco.Name)
+			continue
+		}
+		if len(eco.Directories) != len(dirs) {
+			t.Errorf("DetectPaths() %s directories = %v, want %v", eco.Name, eco.Directories, dirs)
+			continue
+		}
+		for i, dir := range dirs {
+			if eco.Directories[i] != dir {
+				t.Errorf("DetectPaths() %s directories = %v, want %v", eco.Name, eco.Directories, dirs)
+				break
+			}
+		}
+	}
+}
+
+// fakeTree serves a fixed tree and counts how often it was asked for
+type fakeTree struct {
+	paths []string
+	calls int
+}
+
+func (f *fakeTree) GetTree(_ context.Context, _ string) ([]string, error) {
+	f.calls++
+	return f.paths, nil
+}
+
+func TestDetector_DetectRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     []string
+		complete bool
+		wantFull bool
+	}{
+		{
+			name:     "root manifest",
+			root:     []string{"go.mod", "go.sum", ".github/workflows/ci.yml"},
+			complete: true,
+		},
+		{
+			name:     "root manifest with subdirectories",
+			root:     []string{"go.mod", "go.sum", ".github/workflows/ci.yml"},
+			wantFull: true,
+		},
+		{
+			name:     "only workflows",
+			root:     []string{"README.md", ".github/workflows/ci.yml"},
+			complete: true,
+		},
+		{
+			name:     "only workflows with subdirectories",
+			root:     []string{"README.md", ".github/workflows/ci.yml"},
+			wantFull: true,
+		},
+		{
+			name:     "workspace marker",
+			root:     []string{"package.json", "pnpm-workspace.yaml"},
+			complete: true,
+			wantFull: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := &fakeTree{paths: tt.root}
+			if !tt.complete {
+				tree.paths = append([]string{"services/api/go.mod"}, tt.root...)
+			}
+			d := New(tree)
+
+			got, err := d.DetectRoot(context.Background(), "repo", tt.root, tt.complete)
+			if err != nil {
+				t.Fatalf("DetectRoot() error = %v", err)
+			}
+			if (tree.calls > 0) != tt.wantFull {
+				t.Errorf("DetectRoot() scanned the full tree %d times, want full scan %v", tree.calls, tt.wantFull)
+			}
+
+			want := DetectPaths(tt.root)
+			if tt.wantFull {
+				want = DetectPaths(tree.paths)
+			}
+			if len(got) != len(want) {
+				t.Errorf("DetectRoot() found %d ecosystems, want %d", len(got), len(want))
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileSource reads files on the default branch of a repository, returning
+// nil content for files that do not exist. Sources implementing it let the
+// detector resolve workspaces.
+type FileSource interface {
+	GetFileContent(ctx context.Context, repo, path string) ([]byte, string, error)
+}
+
+// workspace is a monorepo whose members Dependabot updates from its root
+type workspace struct {
+	ecosystem string
+	// root is the directory of the workspace manifest
+	root string
+	// members and excludes are patterns of directories, resolved from the
+	// relative ones in the manifest, which may point outside root
+	members  []string
+	excludes []string
+}
+
+// workspaceManifest describes a file that can declare a workspace
+type workspaceManifest struct {
+	ecosystem string
+	file      string
+	// locks are files next to a workspace root that members lack. Manifests
+	// are only read next to a lock or when no ancestor has one, to save
+	// reading every manifest of the repository.
+	locks []string
+	// lockOnly restricts reading to manifests next to a lock
+	lockOnly bool
+	parse    func(content []byte) (members, excludes []string)
+}
+
+// workspaceManifests are the files workspaces are declared in
+var workspaceManifests = []workspaceManifest{
+	{ecosystem: "npm", file: "package.json", locks: []string{"package-lock.json", "yarn.lock", "pnpm-lock.yaml"}, parse: parsePackageJSON},
+	{ecosystem: "npm", file: "pnpm-workspace.yaml", parse: parsePnpmWorkspace},
+	{ecosystem: "gomod", file: "go.work", parse: parseGoWork},
+	{ecosystem: "cargo", file: "Cargo.toml", locks: []string{"Cargo.lock"}, parse: parseCargoWorkspace},
+	// Poetry has no workspaces, but a locked project pulls in the projects
+	// it depends on by path, which a library without a lock does not
+	{ecosystem: "pip", file: "pyproject.toml", locks: []string{"poetry.lock"}, lockOnly: true, parse: parsePoetryPaths},
+}
+
+// resolveWorkspaces removes the members of workspaces from the directories
+// of ecosystems, leaving the workspace roots Dependabot updates them from
+func resolveWorkspaces(ctx context.Context, source FileSource, repo string, paths []string, ecosystems []Ecosystem) ([]Ecosystem, error) {
+	workspaces, err := findWorkspaces(ctx, source, repo, paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(workspaces) == 0 {
+		return ecosystems, nil
+	}
+
+	for i, eco := range ecosystems {
+		dirs := []string{}
+		for _, dir := range eco.Directories {
+			// Members are updated from the root, which a go.work may be
+			// the only file in
+			if root, ok := workspaceRoot(workspaces, eco.Type, dir); ok {
+				dir = root
+			}
+			dirs = appendUnique(dirs, dir)
+		}
+		ecosystems[i].Directories = dirs
+	}
+	return ecosystems, nil
+}
+
+// findWorkspaces reads the workspace manifests among paths
+func findWorkspaces(ctx context.Context, source FileSource, repo string, paths []string) ([]workspace, error) {
+	files := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		files[p] = true
+	}
+
+	var workspaces []workspace
+	for _, manifest := range workspaceManifests {
+		for _, p := range paths {
+			if path.Base(p) != manifest.file || !isWorkspaceCandidate(files, p, manifest) {
+				continue
+			}
+
+			content, _, err := source.GetFileContent(ctx, repo, p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", p, err)
+			}
+			members, excludes := manifest.parse(content)
+			if len(members) == 0 {
+				continue
+			}
+			root := extractDirectory(p)
+			workspaces = append(workspaces, workspace{
+				ecosystem: manifest.ecosystem,
+				root:      root,
+				members:   resolvePatterns(root, members),
+				excludes:  resolvePatterns(root, excludes),
+			})
+		}
+	}
+	return workspaces, nil
+}
+
+// isWorkspaceCandidate reports whether the manifest at p may declare a
+// workspace: it has a lock next to it, or no ancestor directory has the
+// manifest too
+func isWorkspaceCandidate(files map[string]bool, p string, manifest workspaceManifest) bool {
+	if len(manifest.locks) == 0 {
+		return true
+	}
+
+	dir := path.Dir(p)
+	for _, lock := range manifest.locks {
+		if files[path.Join(dir, lock)] {
+			return true
+		}
+	}
+	if manifest.lockOnly {
+		return false
+	}
+	for dir != "." && dir != "/" {
+		dir = path.Dir(dir)
+		if files[path.Join(dir, manifest.file)] {
+			return false
+		}
+	}
+	return true
+}
+
+// workspaceRoot returns the root of the workspace of ecosystem that dir is
+// a member of, if any
+func workspaceRoot(workspaces []workspace, ecosystem, dir string) (string, bool) {
+	for _, ws := range workspaces {
+		if ws.ecosystem != ecosystem || dir == ws.root {
+			continue
+		}
+		if matchesAny(ws.members, dir) && !matchesAny(ws.excludes, dir) {
+			return ws.root, true
+		}
+	}
+	return "", false
+}
+
+// resolvePatterns resolves patterns relative to root into patterns of
+// directories like those of the detector, e.g. /libs/* for ../libs/* in
+// /app
+func resolvePatterns(root string, patterns []string) []string {
+	resolved := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		resolved = append(resolved, path.Join(root, pattern))
+	}
+	return resolved
+}
+
+// matchesAny reports whether dir matches one of patterns, which may use **
+// for any number of directories
+func matchesAny(patterns []string, dir string) bool {
+	segments := strings.Split(strings.Trim(dir, "/"), "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(path.Clean(pattern), "/")
+		if matchSegments(strings.Split(pattern, "/"), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// splitExcludes separates the patterns negated with ! from the others
+func splitExcludes(patterns []string) (members, excludes []string) {
+	for _, pattern := range patterns {
+		if exclude, ok := strings.CutPrefix(pattern, "!"); ok {
+			excludes = append(excludes, exclude)
+		} else {
+			members = append(members, pattern)
+		}
+	}
+	return members, excludes
+}
+
+// parsePackageJSON reads the workspaces of a package.json, given as a list
+// or, as Yarn also accepts, under packages
+func parsePackageJSON(content []byte) ([]string, []string) {
+	var manifest struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil || manifest.Workspaces == nil {
+		return nil, nil
+	}
+
+	var patterns []string
+	if err := json.Unmarshal(manifest.Workspaces, &patterns); err != nil {
+		var yarn struct {
+			Packages []string `json:"packages"`
+		}
+		if err := json.Unmarshal(manifest.Workspaces, &yarn); err != nil {
+			return nil, nil
+		}
+		patterns = yarn.Packages
+	}
+	return splitExcludes(patterns)
+}
+
+// parsePnpmWorkspace reads the packages of a pnpm-workspace.yaml
+func parsePnpmWorkspace(content []byte) ([]string, []string) {
+	var manifest struct {
+		Packages []string `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, nil
+	}
+	return splitExcludes(manifest.Packages)
+}
+
+// parseGoWork reads the modules a go.work uses, from single use directives
+// and use blocks
+func parseGoWork(content []byte) ([]string, []string) {
+	var modules []string
+	inBlock := false
+	for _, line := range strings.Split(string(content), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			modules = append(modules, strings.Trim(fields[0], `"`))
+		case fields[0] == "use(" || (fields[0] == "use" && len(fields) > 1 && fields[1] == "("):
+			inBlock = true
+		case fields[0] == "use" && len(fields) > 1:
+			modules = append(modules, strings.Trim(fields[1], `"`))
+		}
+	}
+	return modules, nil
+}
+
+// quoted matches the strings of a TOML array
+var quoted = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+
+// parseCargoWorkspace reads the members and excludes of the [workspace]
+// table of a Cargo.toml
+func parseCargoWorkspace(content []byte) ([]string, []string) {
+	var members, excludes []string
+	var current *[]string
+	inWorkspace := false
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && current == nil {
+			inWorkspace = line == "[workspace]"
+			continue
+		}
+		if !inWorkspace {
+			continue
+		}
+
+		if current == nil {
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "members":
+				current = &members
+			case "exclude":
+				current = &excludes
+			default:
+				continue
+			}
+			line = value
+		}
+
+		for _, match := range quoted.FindAllStringSubmatch(line, -1) {
+			*current = append(*current, match[1]+match[2])
+		}
+		if strings.Contains(line, "]") {
+			current = nil
+		}
+	}
+	return members, excludes
+}
+
+// poetryDependencyTable matches the tables of a pyproject.toml Poetry lists
+// dependencies in, including a table of a single dependency
+var poetryDependencyTable = regexp.MustCompile(`^\[tool\.poetry\.(dependencies|dev-dependencies|group\.[^.\]]+\.dependencies)(\.[^\]]+)?\]$`)
+
+// poetryPath matches the path of a path dependency, a key of an inline
+// table or of the table of a single dependency
+var poetryPath = regexp.MustCompile(`(?:^|[{,])\s*path\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// parsePoetryPaths reads the directories a pyproject.toml depends on by
+// path in its Poetry dependency tables
+func parsePoetryPaths(content []byte) ([]string, []string) {
+	var paths []string
+	inDependencies := false
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inDependencies = poetryDependencyTable.MatchString(line)
+			continue
+		}
+		if !inDependencies {
+			continue
+		}
+		for _, match := range poetryPath.FindAllStringSubmatch(line, -1) {
+			paths = append(paths, match[1]+match[2])
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,158 @@
+package detector
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeFiles serves a tree and the content of its files
+type fakeFiles struct {
+	files map[string]string
+	reads []string
+}
+
+func (f *fakeFiles) GetTree(_ context.Context, _ string) ([]string, error) {
+	paths := make([]string, 0, len(f.files))
+	for p := range f.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (f *fakeFiles) GetFileContent(_ context.Context, _, path string) ([]byte, string, error) {
+	f.reads = append(f.reads, path)
+	content, ok := f.files[path]
+	if !ok {
+		return nil, "", nil
+	}
+	return []byte(content), "", nil
+}
+
+func TestDetector_Detect_workspaces(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		ecosystem string
+		want      []string
+	}{
+		{
+			name: "npm workspaces",
+			files: map[string]string{
+				"package.json":              `{"workspaces": ["packages/*"]}`,
+				"package-lock.json":         "{}",
+				"packages/a/package.json":   "{}",
+				"packages/b/package.json":   "{}",
+				"tools/script/package.json": "{}",
+			},
+			ecosystem: "npm",
+			want:      []string{"/", "/tools/script"},
+		},
+		{
+			name: "yarn workspaces under packages",
+			files: map[string]string{
+				"package.json":             `{"workspaces": {"packages": ["apps/**"]}}`,
+				"yarn.lock":                "",
+				"apps/web/package.json":    "{}",
+				"apps/web/ui/package.json": "{}",
+			},
+			ecosystem: "npm",
+			want:      []string{"/"},
+		},
+		{
+			name: "pnpm workspace with exclusion",
+			files: map[string]string{
+				"package.json":                 "{}",
+				"pnpm-lock.yaml":               "",
+				"pnpm-workspace.yaml":          "packages:\n  - 'packages/*'\n  - '!packages/legacy'\n",
+				"packages/a/package.json":      "{}",
+				"packages/legacy/package.json": "{}",
+			},
+			ecosystem: "npm",
+			want:      []string{"/", "/packages/legacy"},
+		},
+		{
+			name: "go workspace",
+			files: map[string]string{
+				"go.work":         "go 1.22\n\nuse (\n\t./api // service\n\t./worker\n)\nuse ./tools\n",
+				"api/go.mod":      "module api",
+				"worker/go.mod":   "module worker",
+				"tools/go.mod":    "module tools",
+				"examples/go.mod": "module examples",
+			},
+			ecosystem: "gomod",
+			want:      []string{"/", "/examples"},
+		},
+		{
+			name: "cargo workspace",
+			files: map[string]string{
+				"Cargo.toml":                     "[package]\nname = \"app\"\n\n[workspace]\nmembers = [\n  \"crates/*\", # all crates\n]\nexclude = [\"crates/experimental\"]\n\n[dependencies]\n",
+				"Cargo.lock":                     "",
+				"crates/core/Cargo.toml":         "[package]",
+				"crates/experimental/Cargo.toml": "[package]",
+			},
+			ecosystem: "cargo",
+			want:      []string{"/", "/crates/experimental"},
+		},
+		{
+			name: "poetry path dependencies",
+			files: map[string]string{
+				"app/pyproject.toml":          "[tool.poetry]\nname = \"app\"\n\n[tool.poetry.dependencies]\npython = \"^3.12\"\nshared = { path = \"../libs/shared\", develop = true }\nmy-path = \"1.0\"\n\n[tool.poetry.group.dev.dependencies.testing]\npath = '../libs/testing' # fixtures\n",
+				"app/poetry.lock":             "",
+				"libs/shared/pyproject.toml":  "[tool.poetry]\nname = \"shared\"\n",
+				"libs/testing/pyproject.toml": "[tool.poetry]\nname = \"testing\"\n",
+				"tools/pyproject.toml":        "[tool.poetry.dependencies]\nshared = { path = \"../libs/shared\" }\n",
+			},
+			ecosystem: "pip",
+			want:      []string{"/app", "/tools"},
+		},
+		{
+			name: "no workspace",
+			files: map[string]string{
+				"package.json":           `{"name": "app"}`,
+				"package-lock.json":      "{}",
+				"docs/site/package.json": "{}",
+			},
+			ecosystem: "npm",
+			want:      []string{"/", "/docs/site"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecosystems, err := New(&fakeFiles{files: tt.files}).Detect(context.Background(), "repo")
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+
+			var got []string
+			for _, eco := range ecosystems {
+				if eco.Name == tt.ecosystem {
+					got = append(got, eco.Directories...)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Detect() directories = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetector_Detect_readsWorkspaceRootsOnly(t *testing.T) {
+	source := &fakeFiles{files: map[string]string{
+		"package.json":            `{"workspaces": ["packages/*"]}`,
+		"package-lock.json":       "{}",
+		"packages/a/package.json": "{}",
+		"packages/b/package.json": "{}",
+	}}
+
+	if _, err := New(source).Detect(context.Background(), "repo"); err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if !reflect.DeepEqual(source.reads, []string{"package.json"}) {
+		t.Errorf("expected only the root manifest to be read, got %v", source.reads)
+	}
+}
@@ -0,0 +1,176 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/oauth2"
+)
+
+// appJWTLifetime is how long the JWTs identifying the app are valid. GitHub
+// rejects JWTs that expire more than 10 minutes in the future.
+const appJWTLifetime = 9 * time.Minute
+
+// appClockSkew backdates JWTs to allow for clock drift between us and GitHub
+const appClockSkew = time.Minute
+
+// installationTokenRefresh renews installation tokens this long before they
+// expire, so a token never runs out in the middle of a repository
+const installationTokenRefresh = 5 * time.Minute
+
+// AppCredentials identifies a GitHub App installation to authenticate as
+type AppCredentials struct {
+	AppID int64
+	// PrivateKey is the PEM encoded private key of the app
+	PrivateKey []byte
+	// InstallationID is looked up from the organization when zero
+	InstallationID int64
+}
+
+// NewAppClient creates a GitHub client authenticated as a GitHub App
+// installation. Installation tokens are minted on demand and refreshed
+// before they expire, so commits and pull requests are made by the app.
+func NewAppClient(creds AppCredentials, org string, endpoint Endpoint) (*Client, error) {
+	key, err := parsePrivateKey(creds.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := endpoint.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	// Installation tokens are minted from the same endpoint they are used on
+	apps, err := endpoint.newGitHubClient(&http.Client{
+		Transport: &appTransport{appID: creds.AppID, key: key, base: base},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(newInstallationTokenSource(apps, org, creds.InstallationID), org, endpoint)
+}
+
+// newInstallationTokenSource returns a token source minting installation
+// tokens with apps, a client authenticated as the app itself
+func newInstallationTokenSource(apps *github.Client, org string, installationID int64) oauth2.TokenSource {
+	source := &installationTokenSource{
+		apps:           apps,
+		org:            org,
+		installationID: installationID,
+	}
+	return oauth2.ReuseTokenSourceWithExpiry(nil, source, installationTokenRefresh)
+}
+
+// installationTokenSource mints installation access tokens. It is wrapped in
+// a reusing token source, which serializes calls to Token.
+type installationTokenSource struct {
+	apps           *github.Client
+	org            string
+	installationID int64
+}
+
+// Token implements oauth2.TokenSource
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	ctx := context.Background()
+
+	if s.installationID == 0 {
+		installation, _, err := s.apps.Apps.FindOrganizationInstallation(ctx, s.org)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find app installation for %s: %w", s.org, err)
+		}
+		s.installationID = installation.GetID()
+	}
+
+	token, _, err := s.apps.Apps.CreateInstallationToken(ctx, s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "token",
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
+
+// appTransport authenticates requests as the app itself with a fresh JWT
+type appTransport struct {
+	appID int64
+	key   *rsa.PrivateKey
+	base  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := signAppJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	return t.base.RoundTrip(req)
+}
+
+// signAppJWT creates the RS256 signed JWT GitHub expects from an app
+func signAppJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign app JWT: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes a PEM encoded RSA key in PKCS#1 or PKCS#8 form
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("app private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("app private key is not an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,194 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// verifyAppJWT checks the signature of a JWT and returns its claims
+func verifyAppJWT(t *testing.T, jwt string, key *rsa.PublicKey) map[string]interface{} {
+	t.Helper()
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT %q", jwt)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("invalid JWT signature: %v", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestSignAppJWT(t *testing.T) {
+	key := newTestKey(t)
+	now := time.Unix(1700000000, 0)
+
+	jwt, err := signAppJWT(12345, key, now)
+	if err != nil {
+		t.Fatalf("signAppJWT() error = %v", err)
+	}
+
+	claims := verifyAppJWT(t, jwt, &key.PublicKey)
+	if claims["iss"] != "12345" {
+		t.Errorf("iss = %v, want 12345", claims["iss"])
+	}
+	if iat := int64(claims["iat"].(float64)); iat != now.Add(-appClockSkew).Unix() {
+		t.Errorf("iat = %d, want %d", iat, now.Add(-appClockSkew).Unix())
+	}
+	if exp := int64(claims["exp"].(float64)); exp-now.Unix() > int64((10 * time.Minute).Seconds()) {
+		t.Errorf("exp %d is more than 10 minutes ahead", exp)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key := newTestKey(t)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name: "PKCS#1",
+			data: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+		{
+			name: "PKCS#8",
+			data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		},
+		{
+			name:    "not PEM",
+			data:    []byte("not a key"),
+			wantErr: true,
+		},
+		{
+			name:    "garbage",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePrivateKey(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(key) {
+				t.Errorf("parsePrivateKey() returned a different key")
+			}
+		})
+	}
+}
+
+func TestInstallationTokenSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		lifetime   time.Duration
+		wantMinted int32
+	}{
+		{
+			name:       "token is reused while valid",
+			lifetime:   time.Hour,
+			wantMinted: 1,
+		},
+		{
+			name:       "token is refreshed before it expires",
+			lifetime:   time.Minute,
+			wantMinted: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newTestKey(t)
+			var lookups, minted atomic.Int32
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/v3/orgs/acme/installation", func(w http.ResponseWriter, r *http.Request) {
+				verifyAppJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey)
+				lookups.Add(1)
+				fmt.Fprint(w, `{"id": 42}`)
+			})
+			mux.HandleFunc("POST /api/v3/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+				verifyAppJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey)
+				n := minted.Add(1)
+				expires := time.Now().Add(tt.lifetime).UTC().Format(time.RFC3339)
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, n, expires)
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			apps, err := github.NewEnterpriseClient(srv.URL, srv.URL, &http.Client{
+				Transport: &appTransport{appID: 1, key: key, base: http.DefaultTransport},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			source := newInstallationTokenSource(apps, "acme", 0)
+			for i := 0; i < 3; i++ {
+				token, err := source.Token()
+				if err != nil {
+					t.Fatalf("Token() error = %v", err)
+				}
+				if !strings.HasPrefix(token.AccessToken, "ghs_") {
+					t.Errorf("unexpected token %q", token.AccessToken)
+				}
+			}
+
+			if got := minted.Load(); got != tt.wantMinted {
+				t.Errorf("minted %d tokens, want %d", got, tt.wantMinted)
+			}
+			if got := lookups.Load(); got != 1 {
+				t.Errorf("looked up the installation %d times, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Cache keeps GET responses on disk across runs and revalidates them with
+// conditional requests. GitHub answers an unchanged resource with 304 Not
+// Modified, which does not count against the rate limit.
+type Cache struct {
+	dir    string
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// cacheEntry is a stored response
+type cacheEntry struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// NewCache creates a cache storing responses in dir, creating it if needed
+func NewCache(dir string) (*Cache, error) {
+	// Responses may contain private repository content
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Stats returns how many cacheable requests were answered from the cache
+// and how many had to be downloaded
+func (c *Cache) Stats() (hits, misses int) {
+	return int(c.hits.Load()), int(c.misses.Load())
+}
+
+// Transport wraps base so GET requests are revalidated against the cache
+func (c *Cache) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &cacheTransport{cache: c, base: base}
+}
+
+// cacheTransport sends conditional requests for cached responses
+type cacheTransport struct {
+	cache *Cache
+	base  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests that are already conditional are left to the caller
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := t.cache.key(req)
+	entry := t.cache.load(key)
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := entry.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_ = resp.Body.Close()
+		t.cache.hits.Add(1)
+		return entry.response(req, resp), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.cache.misses.Add(1)
+	// A failed write only costs a download on the next run
+	_ = t.cache.store(key, &cacheEntry{URL: req.URL.String(), Header: resp.Header, Body: body})
+
+	return resp, nil
+}
+
+// response rebuilds the cached response. Headers sent with the 304, such as
+// the rate limit, replace the stored ones.
+func (e *cacheEntry) response(req *http.Request, notModified *http.Response) *http.Response {
+	header := e.Header.Clone()
+	for name, values := range notModified.Header {
+		header[name] = values
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// key derives the file name of the entry for a request. The media type is
+// part of it because the same URL can be requested in different formats.
+func (c *Cache) key(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Header.Get("Accept") + " " + req.URL.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// load reads the entry stored under key, or nil if there is none
+func (c *Cache) load(key string) *cacheEntry {
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// store writes an entry under key, replacing it atomically so concurrent
+// readers never see a partial file
+func (c *Cache) store(key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, key+".json"))
+}
@@ -0,0 +1,110 @@
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheTransport(t *testing.T) {
+	body := `{"name":"repo"}`
+	var conditional, full int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	get := func(cache *Cache) *http.Response {
+		t.Helper()
+		client := &http.Client{Transport: cache.Transport(srv.Client().Transport)}
+		resp, err := client.Get(srv.URL + "/repos/acme/repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first, err := NewCache(dir)
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	resp := get(first)
+	_ = resp.Body.Close()
+
+	// A later run with a new cache on the same directory revalidates
+	second, err := NewCache(dir)
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	resp = get(second)
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || string(data) != body {
+		t.Errorf("expected the cached 200 response, got %d %q", resp.StatusCode, data)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("X-RateLimit-Remaining") != "4999" {
+		t.Errorf("expected stored and fresh headers, got %v", resp.Header)
+	}
+	if full != 1 || conditional != 1 {
+		t.Errorf("expected 1 full and 1 conditional request, got %d and %d", full, conditional)
+	}
+	if hits, misses := second.Stats(); hits != 1 || misses != 0 {
+		t.Errorf("Stats() = %d hits, %d misses, want 1, 0", hits, misses)
+	}
+}
+
+func TestCacheTransport_SkipsUncacheable(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.Header.Get("If-None-Match") != "" {
+			t.Errorf("unexpected conditional %s request", req.Method)
+		}
+		if req.Method == http.MethodGet {
+			// No validator, so nothing to revalidate later
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	defer srv.Close()
+
+	cache, err := NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cache.Transport(srv.Client().Transport)}
+
+	for i := 0; i < 2; i++ {
+		for _, method := range []string{http.MethodGet, http.MethodPut} {
+			req, _ := http.NewRequest(method, srv.URL+"/contents/file", nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+		}
+	}
+
+	if hits, misses := cache.Stats(); hits != 0 || misses != 0 {
+		t.Errorf("Stats() = %d hits, %d misses, want nothing cached", hits, misses)
+	}
+	if requests != 4 {
+		t.Errorf("expected 4 requests, got %d", requests)
+	}
+}
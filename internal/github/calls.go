@@ -0,0 +1,49 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// CallCounter counts the API requests made with a context, e.g. while
+// processing one repository
+type CallCounter struct {
+	calls atomic.Int64
+}
+
+// Count returns the number of requests made so far
+func (c *CallCounter) Count() int {
+	return int(c.calls.Load())
+}
+
+type callCounterKey struct{}
+
+// WithCallCounter returns a context whose API requests are counted by the
+// returned counter
+func WithCallCounter(ctx context.Context) (context.Context, *CallCounter) {
+	counter := &CallCounter{}
+	return context.WithValue(ctx, callCounterKey{}, counter), counter
+}
+
+// countingTransport increments the counter carried by a request's context
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+// CountingTransport wraps base so requests made with a context from
+// WithCallCounter are counted
+func CountingTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &countingTransport{base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if counter, ok := req.Context().Value(callCounterKey{}).(*CallCounter); ok {
+		counter.calls.Add(1)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -4,36 +4,121 @@ package github
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"time"
+	"net/http"
 
 	"github.com/google/go-github/v50/github"
 	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
-	"github.com/enthus-appdev/dependabot-config-manager/internal/util"
 	"golang.org/x/oauth2"
 	"gopkg.in/yaml.v3"
 )
 
+// ConfigPath is where the Dependabot configuration is written when a
+// repository has none
+const ConfigPath = ".github/dependabot.yml"
+
+// ConfigPaths are the locations Dependabot reads its configuration from, in
+// order of precedence
+var ConfigPaths = []string{ConfigPath, ".github/dependabot.yaml"}
+
+// API is the set of GitHub operations the synchronizer depends on. *Client
+// implements it against the real REST API.
+type API interface {
+	ListRepositories(ctx context.Context, excludeArchived bool) ([]*github.Repository, error)
+	GetRepository(ctx context.Context, name string) (*github.Repository, error)
+	GetTree(ctx context.Context, repo string) ([]string, error)
+	GetFileContent(ctx context.Context, repo, path string) ([]byte, string, error)
+	GetExistingConfig(ctx context.Context, repo string) (*config.DependabotConfig, []byte, string, error)
+	GetCodeOwnersTeam(ctx context.Context, repo string) (string, error)
+	CreateOrUpdateFile(ctx context.Context, repo, path, message string, content []byte, sha string) error
+	CreatePullRequest(ctx context.Context, repo, path string, cfg *config.DependabotConfig, content []byte) (*PullRequestResult, error)
+	CloseSyncPullRequest(ctx context.Context, repo string) (bool, error)
+	GetSnapshots(ctx context.Context, repos []string) (map[string]*RepositorySnapshot, error)
+	GetTreeSHA(ctx context.Context, repo, branch string) (string, error)
+}
+
+var _ API = (*Client)(nil)
+
 // Client wraps the GitHub client with our specific operations
 type Client struct {
-	client *github.Client
-	org    string
+	client  *github.Client
+	org     string
+	limiter *RateLimiter
+	cache   *Cache
+	retry   RetryPolicy
 }
 
-// NewClient creates a new GitHub client
-func NewClient(token, org string) *Client {
-	ctx := context.Background()
+// NewClient creates a new GitHub client authenticating with a token
+func NewClient(token, org string, endpoint Endpoint) (*Client, error) {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	tc := oauth2.NewClient(ctx, ts)
+
+	return newClient(ts, org, endpoint)
+}
+
+// newClient creates a GitHub client authenticating with tokens from ts
+func newClient(ts oauth2.TokenSource, org string, endpoint Endpoint) (*Client, error) {
+	base, err := endpoint.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	var cache *Cache
+	if endpoint.CacheDir != "" {
+		cache, err = NewCache(endpoint.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		base = cache.Transport(base)
+	}
+
+	limiter := NewRateLimiter()
+	client, err := endpoint.newGitHubClient(&http.Client{
+		Transport: CountingTransport(limiter.Transport(&oauth2.Transport{Source: ts, Base: base})),
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &Client{
-		client: github.NewClient(tc),
+		client:  client,
+		org:     org,
+		limiter: limiter,
+		cache:   cache,
+		retry:   DefaultRetryPolicy,
+	}, nil
+}
+
+// NewClientFromGitHub wraps an already configured GitHub client, e.g. one
+// pointing at a fake server in tests
+func NewClientFromGitHub(client *github.Client, org string) *Client {
+	return &Client{
+		client: client,
 		org:    org,
+		retry:  DefaultRetryPolicy,
 	}
 }
 
+// SetRetryPolicy changes how requests failing with a transient error are
+// retried
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// RateLimiter returns the rate limiter requests go through, or nil if the
+// client was not created with one
+func (c *Client) RateLimiter() *RateLimiter {
+	return c.limiter
+}
+
+// Cache returns the response cache requests go through, or nil if caching
+// is disabled
+func (c *Client) Cache() *Cache {
+	return c.cache
+}
+
 // GetClient returns the underlying GitHub client
 func (c *Client) GetClient() *github.Client {
 	return c.client
@@ -49,7 +134,13 @@ func (c *Client) ListRepositories(ctx context.Context, excludeArchived bool) ([]
 	}
 
 	for {
-		repos, resp, err := c.client.Repositories.ListByOrg(ctx, c.org, opt)
+		var repos []*github.Repository
+		var resp *github.Response
+		err := c.retry.do(ctx, func() error {
+			var err error
+			repos, resp, err = c.client.Repositories.ListByOrg(ctx, c.org, opt)
+			return err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to list repositories: %w", err)
 		}
@@ -72,18 +163,61 @@ func (c *Client) ListRepositories(ctx context.Context, excludeArchived bool) ([]
 
 // GetRepository gets a single repository
 func (c *Client) GetRepository(ctx context.Context, name string) (*github.Repository, error) {
-	repo, _, err := c.client.Repositories.Get(ctx, c.org, name)
+	var repo *github.Repository
+	err := c.retry.do(ctx, func() error {
+		var err error
+		repo, _, err = c.client.Repositories.Get(ctx, c.org, name)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get repository: %w", err)
 	}
 	return repo, nil
 }
 
+// GetTree lists the paths of all files on the default branch of a repository
+func (c *Client) GetTree(ctx context.Context, repo string) ([]string, error) {
+	var tree *github.Tree
+	err := c.retry.do(ctx, func() error {
+		var err error
+		tree, _, err = c.client.Git.GetTree(ctx, c.org, repo, "HEAD", true)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" && entry.Path != nil {
+			paths = append(paths, entry.GetPath())
+		}
+	}
+
+	return paths, nil
+}
+
 // GetFileContent gets the content of a file from a repository
 func (c *Client) GetFileContent(ctx context.Context, repo, path string) ([]byte, string, error) {
-	fileContent, _, resp, err := c.client.Repositories.GetContents(ctx, c.org, repo, path, nil)
+	return c.getFileContentAt(ctx, repo, path, "")
+}
+
+// getFileContentAt gets the content of a file at a ref, or on the default
+// branch when ref is empty
+func (c *Client) getFileContentAt(ctx context.Context, repo, path, ref string) ([]byte, string, error) {
+	var opts *github.RepositoryContentGetOptions
+	if ref != "" {
+		opts = &github.RepositoryContentGetOptions{Ref: ref}
+	}
+
+	var fileContent *github.RepositoryContent
+	err := c.retry.do(ctx, func() error {
+		var err error
+		fileContent, _, _, err = c.client.Repositories.GetContents(ctx, c.org, repo, path, opts)
+		return err
+	})
 	if err != nil {
-		if resp != nil && resp.StatusCode == 404 {
+		if errors.Is(err, ErrNotFound) {
 			return nil, "", nil // File not found
 		}
 		return nil, "", fmt.Errorf("failed to get file content: %w", err)
@@ -106,17 +240,13 @@ func (c *Client) GetFileContent(ctx context.Context, repo, path string) ([]byte,
 	return content, sha, nil
 }
 
-// CreateOrUpdateFile creates or updates a file in a repository
+// CreateOrUpdateFile creates or updates a file on the default branch of a
+// repository. sha is the blob SHA of the file as it was read, or empty if it
+// did not exist; the error wraps ErrConflict if the file changed since.
 func (c *Client) CreateOrUpdateFile(ctx context.Context, repo, path, message string, content []byte, sha string) error {
-	// Get repository info to determine default branch
-	repoInfo, _, err := c.client.Repositories.Get(ctx, c.org, repo)
+	defaultBranch, err := c.getDefaultBranch(ctx, repo)
 	if err != nil {
-		return fmt.Errorf("failed to get repository info: %w", err)
-	}
-
-	defaultBranch := "main"
-	if repoInfo.DefaultBranch != nil {
-		defaultBranch = *repoInfo.DefaultBranch
+		return err
 	}
 
 	opts := &github.RepositoryContentFileOptions{
@@ -129,149 +259,59 @@ func (c *Client) CreateOrUpdateFile(ctx context.Context, repo, path, message str
 		opts.SHA = &sha
 	}
 
-	_, _, err = c.client.Repositories.CreateFile(ctx, c.org, repo, path, opts)
+	err = c.retry.do(ctx, func() error {
+		_, _, err := c.client.Repositories.CreateFile(ctx, c.org, repo, path, opts)
+		return err
+	})
 	if err != nil {
-		// If creation fails, try update
-		if sha == "" {
-			// Get current SHA
-			currentContent, currentSHA, getErr := c.GetFileContent(ctx, repo, path)
-			if getErr != nil {
-				return fmt.Errorf("failed to create or update file: %w", err)
-			}
-			if currentContent != nil {
-				opts.SHA = &currentSHA
-				_, _, err = c.client.Repositories.UpdateFile(ctx, c.org, repo, path, opts)
-			}
-		}
-	}
-
-	return err
-}
-
-// CreatePullRequest creates a pull request for the Dependabot configuration
-func (c *Client) CreatePullRequest(ctx context.Context, repo string, config *config.DependabotConfig, yamlIndent int) error {
-	// Create a branch
-	branchName := fmt.Sprintf("dependabot-config-%d", time.Now().Unix())
-
-	// Get default branch
-	repoInfo, _, err := c.client.Repositories.Get(ctx, c.org, repo)
-	if err != nil {
-		return fmt.Errorf("failed to get repository info: %w", err)
-	}
-
-	defaultBranch := "main"
-	if repoInfo.DefaultBranch != nil {
-		defaultBranch = *repoInfo.DefaultBranch
-	}
-
-	// Get reference of default branch
-	ref, _, err := c.client.Git.GetRef(ctx, c.org, repo, "refs/heads/"+defaultBranch)
-	if err != nil {
-		return fmt.Errorf("failed to get reference: %w", err)
-	}
-
-	// Create new branch
-	newRef := &github.Reference{
-		Ref: github.String("refs/heads/" + branchName),
-		Object: &github.GitObject{
-			SHA: ref.Object.SHA,
-		},
-	}
-
-	_, _, err = c.client.Git.CreateRef(ctx, c.org, repo, newRef)
-	if err != nil {
-		return fmt.Errorf("failed to create branch: %w", err)
-	}
-
-	// Create or update the Dependabot config file on the new branch
-	content, err := util.MarshalYAML(config, yamlIndent)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	message := "Add/Update Dependabot configuration"
-	opts := &github.RepositoryContentFileOptions{
-		Message: &message,
-		Content: content,
-		Branch:  &branchName,
-	}
-
-	// Check if file exists
-	existingContent, sha, _ := c.GetFileContent(ctx, repo, ".github/dependabot.yml")
-	if existingContent != nil {
-		opts.SHA = &sha
-		_, _, err = c.client.Repositories.UpdateFile(ctx, c.org, repo, ".github/dependabot.yml", opts)
-	} else {
-		_, _, err = c.client.Repositories.CreateFile(ctx, c.org, repo, ".github/dependabot.yml", opts)
-	}
-
-	if err != nil {
-		return fmt.Errorf("failed to create/update file in branch: %w", err)
-	}
-
-	// Create pull request
-	prTitle := "Configure Dependabot for dependency updates"
-	prBody := generatePRBody(config)
-
-	pr := &github.NewPullRequest{
-		Title:               &prTitle,
-		Head:                &branchName,
-		Base:                &defaultBranch,
-		Body:                &prBody,
-		MaintainerCanModify: github.Bool(true),
-	}
-
-	_, _, err = c.client.PullRequests.Create(ctx, c.org, repo, pr)
-	if err != nil {
-		return fmt.Errorf("failed to create pull request: %w", err)
+		return fmt.Errorf("failed to create or update file: %w", err)
 	}
 
 	return nil
 }
 
-// GetExistingConfig retrieves the existing Dependabot configuration
-func (c *Client) GetExistingConfig(ctx context.Context, repo string) (*config.DependabotConfig, error) {
-	content, _, err := c.GetFileContent(ctx, repo, ".github/dependabot.yml")
-	if err != nil {
-		return nil, err
-	}
-
-	if content == nil {
-		// Try alternative path
-		content, _, err = c.GetFileContent(ctx, repo, ".github/dependabot.yaml")
+// GetExistingConfig retrieves the existing Dependabot configuration along
+// with its raw file content and the path it was read from. Without a
+// configuration it returns nil and ConfigPath.
+func (c *Client) GetExistingConfig(ctx context.Context, repo string) (*config.DependabotConfig, []byte, string, error) {
+	for _, path := range ConfigPaths {
+		content, _, err := c.GetFileContent(ctx, repo, path)
 		if err != nil {
-			return nil, err
+			return nil, nil, "", err
+		}
+		if content == nil {
+			continue
 		}
-	}
-
-	if content == nil {
-		return nil, nil // No existing config
-	}
 
-	var cfg config.DependabotConfig
-	if err := yaml.Unmarshal(content, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse existing config: %w", err)
+		var cfg config.DependabotConfig
+		if err := yaml.Unmarshal(content, &cfg); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to parse existing config: %w", err)
+		}
+		return &cfg, content, path, nil
 	}
 
-	return &cfg, nil
+	return nil, nil, ConfigPath, nil
 }
 
-// GetTreeSHA gets the SHA of the repository tree
-func (c *Client) GetTreeSHA(ctx context.Context, repo string) (string, error) {
-	// Get repository info to determine default branch
-	repoInfo, _, err := c.client.Repositories.Get(ctx, c.org, repo)
-	if err != nil {
-		return "", fmt.Errorf("failed to get repository info: %w", err)
-	}
-
-	defaultBranch := "main"
-	if repoInfo.DefaultBranch != nil {
-		defaultBranch = *repoInfo.DefaultBranch
+// GetTreeSHA gets the SHA of the commit branch points at. An empty branch
+// stands for the default branch, which costs an extra request to look up.
+func (c *Client) GetTreeSHA(ctx context.Context, repo, branch string) (string, error) {
+	if branch == "" {
+		var err error
+		branch, err = c.getDefaultBranch(ctx, repo)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	ref, _, err := c.client.Git.GetRef(ctx, c.org, repo, "refs/heads/"+defaultBranch)
+	var ref *github.Reference
+	err := c.retry.do(ctx, func() error {
+		var err error
+		ref, _, err = c.client.Git.GetRef(ctx, c.org, repo, "refs/heads/"+branch)
+		return err
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to get ref for branch %s: %w", defaultBranch, err)
+		return "", fmt.Errorf("failed to get ref for branch %s: %w", branch, err)
 	}
 
 	if ref.Object != nil && ref.Object.SHA != nil {
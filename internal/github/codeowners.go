@@ -0,0 +1,53 @@
+package github
+
+import (
+	"context"
+	"strings"
+)
+
+// CodeOwnersPaths are the locations GitHub reads CODEOWNERS from, in order
+// of precedence
+var CodeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// rootPatterns are the CODEOWNERS patterns that match every file
+var rootPatterns = map[string]bool{"*": true, "/": true, "/*": true, "/**": true, "**": true}
+
+// RootTeam returns the first team owning the repository root in a CODEOWNERS
+// file as org/team, or an empty string if no team does. Later rules take
+// precedence, as they do on GitHub.
+func RootTeam(content []byte) string {
+	var team string
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !rootPatterns[fields[0]] {
+			continue
+		}
+
+		team = ""
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "@") && strings.Contains(owner, "/") {
+				team = strings.TrimPrefix(owner, "@")
+				break
+			}
+		}
+	}
+	return team
+}
+
+// GetCodeOwnersTeam returns the team owning the root of a repository
+// according to its CODEOWNERS file, or an empty string if there is none
+func (c *Client) GetCodeOwnersTeam(ctx context.Context, repo string) (string, error) {
+	for _, path := range CodeOwnersPaths {
+		content, _, err := c.GetFileContent(ctx, repo, path)
+		if err != nil {
+			return "", err
+		}
+		if content != nil {
+			return RootTeam(content), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,28 @@
+package github
+
+import "testing"
+
+func TestRootTeam(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "wildcard", content: "* @acme/platform\n", want: "acme/platform"},
+		{name: "first team", content: "* @alice @acme/platform @acme/security\n", want: "acme/platform"},
+		{name: "last root rule wins", content: "* @acme/platform\n/docs/ @acme/docs\n/** @acme/backend\n", want: "acme/backend"},
+		{name: "comments", content: "# * @acme/old\n* @acme/platform # owners\n", want: "acme/platform"},
+		{name: "only users", content: "* @alice\n", want: ""},
+		{name: "unowned root", content: "* @acme/platform\n*\n", want: ""},
+		{name: "no root rule", content: "/src/ @acme/backend\n", want: ""},
+		{name: "empty", content: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RootTeam([]byte(tt.content)); got != tt.want {
+				t.Errorf("RootTeam() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
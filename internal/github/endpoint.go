@@ -0,0 +1,86 @@
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// Endpoint configures where the GitHub API is reached. The zero value
+// targets github.com.
+type Endpoint struct {
+	// BaseURL is the API URL of a GitHub Enterprise Server instance, e.g.
+	// https://github.example.com/api/v3/
+	BaseURL string
+	// UploadURL is the upload API URL; it defaults to /api/uploads/ on the
+	// host of BaseURL
+	UploadURL string
+	// CABundle is a PEM file with certificates to trust in addition to the
+	// system roots, for instances using a private CA
+	CABundle string
+	// CacheDir, if set, keeps API responses on disk so later runs only
+	// revalidate them; see Cache
+	CacheDir string
+}
+
+// transport returns the base HTTP transport for the endpoint
+func (e Endpoint) transport() (http.RoundTripper, error) {
+	if e.CABundle == "" {
+		return http.DefaultTransport, nil
+	}
+
+	data, err := os.ReadFile(e.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", e.CABundle)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return transport, nil
+}
+
+// newGitHubClient creates a go-github client for the endpoint
+func (e Endpoint) newGitHubClient(httpClient *http.Client) (*github.Client, error) {
+	// GitHub Actions sets GITHUB_API_URL to api.github.com on github.com
+	if e.BaseURL == "" || isGitHubDotCom(e.BaseURL) {
+		return github.NewClient(httpClient), nil
+	}
+
+	uploadURL := e.UploadURL
+	if uploadURL == "" {
+		base, err := url.Parse(e.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub Enterprise URL: %w", err)
+		}
+		uploadURL = (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/api/uploads/"}).String()
+	}
+
+	client, err := github.NewEnterpriseClient(e.BaseURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub Enterprise URL: %w", err)
+	}
+	return client, nil
+}
+
+// isGitHubDotCom reports whether rawURL points at the github.com API
+func isGitHubDotCom(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.EqualFold(u.Hostname(), "api.github.com")
+}
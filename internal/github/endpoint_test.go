@@ -0,0 +1,93 @@
+package github
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEndpoint_newGitHubClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   Endpoint
+		wantBase   string
+		wantUpload string
+	}{
+		{
+			name:       "github.com by default",
+			endpoint:   Endpoint{},
+			wantBase:   "https://api.github.com/",
+			wantUpload: "https://uploads.github.com/",
+		},
+		{
+			name:       "api.github.com as set by GitHub Actions",
+			endpoint:   Endpoint{BaseURL: "https://api.github.com"},
+			wantBase:   "https://api.github.com/",
+			wantUpload: "https://uploads.github.com/",
+		},
+		{
+			name:       "enterprise host",
+			endpoint:   Endpoint{BaseURL: "https://github.example.com"},
+			wantBase:   "https://github.example.com/api/v3/",
+			wantUpload: "https://github.example.com/api/uploads/",
+		},
+		{
+			name:       "enterprise API URL",
+			endpoint:   Endpoint{BaseURL: "https://github.example.com/api/v3/"},
+			wantBase:   "https://github.example.com/api/v3/",
+			wantUpload: "https://github.example.com/api/uploads/",
+		},
+		{
+			name: "enterprise with separate upload URL",
+			endpoint: Endpoint{
+				BaseURL:   "https://github.example.com/api/v3/",
+				UploadURL: "https://uploads.github.example.com/api/uploads/",
+			},
+			wantBase:   "https://github.example.com/api/v3/",
+			wantUpload: "https://uploads.github.example.com/api/uploads/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := tt.endpoint.newGitHubClient(http.DefaultClient)
+			if err != nil {
+				t.Fatalf("newGitHubClient() error = %v", err)
+			}
+			if got := client.BaseURL.String(); got != tt.wantBase {
+				t.Errorf("BaseURL = %s, want %s", got, tt.wantBase)
+			}
+			if got := client.UploadURL.String(); got != tt.wantUpload {
+				t.Errorf("UploadURL = %s, want %s", got, tt.wantUpload)
+			}
+		})
+	}
+}
+
+func TestEndpoint_transport(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, []byte("no certificates here"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		caBundle string
+		wantErr  bool
+	}{
+		{name: "system roots", caBundle: ""},
+		{name: "missing bundle", caBundle: filepath.Join(dir, "missing.pem"), wantErr: true},
+		{name: "bundle without certificates", caBundle: empty, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Endpoint{CABundle: tt.caBundle}.transport()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("transport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// Kinds of GitHub API errors. Errors returned by Client wrap one of them when
+// the failure could be classified, so callers can use errors.Is.
+var (
+	// ErrNotFound means the repository, file or reference does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the file changed since it was read, e.g. its SHA no
+	// longer matches
+	ErrConflict = errors.New("conflict")
+	// ErrForbidden means the credentials lack access to the resource
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited means GitHub kept rejecting requests for exceeding a
+	// rate limit
+	ErrRateLimited = errors.New("rate limited")
+	// ErrTransient means a server error or network failure that may succeed
+	// when retried
+	ErrTransient = errors.New("transient error")
+)
+
+// Error is a GitHub API error classified by kind
+type Error struct {
+	// Kind is one of the Err* kinds above
+	Kind error
+	Err  error
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap makes both the kind and the underlying error match errors.Is
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classify wraps err with its kind. Errors that cannot be classified, such
+// as validation failures or a canceled context, are returned unchanged.
+func classify(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	if kind := errorKind(err); kind != nil {
+		return &Error{Kind: kind, Err: err}
+	}
+	return err
+}
+
+// errorKind determines the kind of err, or nil if it has none
+func errorKind(err error) error {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+		return ErrRateLimited
+	}
+
+	var respErr *github.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		status := respErr.Response.StatusCode
+		switch {
+		case status == http.StatusNotFound:
+			return ErrNotFound
+		case status == http.StatusConflict:
+			return ErrConflict
+		case status == http.StatusUnprocessableEntity && strings.Contains(respErr.Message, `"sha"`):
+			// The file was created since it was read
+			return ErrConflict
+		case status == http.StatusTooManyRequests:
+			return ErrRateLimited
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return ErrForbidden
+		case status >= http.StatusInternalServerError:
+			return ErrTransient
+		}
+		return nil
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTransient
+	}
+	return nil
+}
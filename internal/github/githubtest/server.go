@@ -0,0 +1,1091 @@
+// Package githubtest provides an in-memory fake of the GitHub REST API, and of
+// the GraphQL query used to snapshot repositories, for end-to-end tests.
+//
+// Repositories are loaded from fixture directories on disk: every
+// subdirectory of the fixtures directory becomes a repository whose default
+// branch contains the files below it. Writes made through the API are kept in
+// memory and recorded so tests can assert on the exact commits and pull
+// requests a sync run produced.
+package githubtest
+
+import (
+	"crypto/sha1" //nolint:gosec // git object IDs are SHA-1
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// MetadataFile is the optional per-repository fixture file describing the
+// repository itself. It is not part of the repository tree.
+const MetadataFile = ".fixture.json"
+
+// RepoMetadata holds the repository attributes a fixture can override
+type RepoMetadata struct {
+	DefaultBranch string   `json:"default_branch"`
+	Topics        []string `json:"topics"`
+	Archived      bool     `json:"archived"`
+	Private       bool     `json:"private"`
+	Language      string   `json:"language"`
+}
+
+// Commit records a file written through the contents API
+type Commit struct {
+	SHA     string
+	Branch  string
+	Path    string
+	Message string
+	Content []byte
+}
+
+// PullRequest records a pull request opened through the pulls API
+type PullRequest struct {
+	Number int
+	Title  string
+	Body   string
+	Head   string
+	Base   string
+	State  string
+	Labels []string
+	// HeadRepo is the full name of the repository Head is in, when the pull
+	// request comes from a fork
+	HeadRepo string
+}
+
+type branch struct {
+	sha   string
+	files map[string][]byte
+}
+
+type repository struct {
+	name     string
+	meta     RepoMetadata
+	branches map[string]*branch
+	commits  []Commit
+	pulls    []*PullRequest
+}
+
+// Server is a fake GitHub API server backed by fixture repositories
+type Server struct {
+	*httptest.Server
+
+	org   string
+	mu    sync.Mutex
+	repos map[string]*repository
+	seq   int
+}
+
+// NewServer starts a fake GitHub server for org serving the repositories
+// found in fixturesDir. Call Close when done.
+func NewServer(org, fixturesDir string) (*Server, error) {
+	return newServer(org, fixturesDir, httptest.NewServer)
+}
+
+// NewTLSServer is like NewServer but serves HTTPS with a self-signed
+// certificate, available from Certificate, like an Enterprise Server
+// instance behind a private CA
+func NewTLSServer(org, fixturesDir string) (*Server, error) {
+	return newServer(org, fixturesDir, httptest.NewTLSServer)
+}
+
+func newServer(org, fixturesDir string, start func(http.Handler) *httptest.Server) (*Server, error) {
+	s := &Server{
+		org:   org,
+		repos: make(map[string]*repository),
+	}
+
+	if err := s.loadFixtures(fixturesDir); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/orgs/{org}/repos", s.handleListRepos)
+	mux.HandleFunc("GET /api/v3/repos/{owner}/{repo}", s.handleGetRepo)
+	mux.HandleFunc("GET /api/v3/repos/{owner}/{repo}/git/trees/{sha}", s.handleGetTree)
+	mux.HandleFunc("GET /api/v3/repos/{owner}/{repo}/git/ref/{ref...}", s.handleGetRef)
+	mux.HandleFunc("POST /api/v3/repos/{owner}/{repo}/git/refs", s.handleCreateRef)
+	mux.HandleFunc("PATCH /api/v3/repos/{owner}/{repo}/git/refs/{ref...}", s.handleUpdateRef)
+	mux.HandleFunc("DELETE /api/v3/repos/{owner}/{repo}/git/refs/{ref...}", s.handleDeleteRef)
+	mux.HandleFunc("GET /api/v3/repos/{owner}/{repo}/contents/{path...}", s.handleGetContents)
+	mux.HandleFunc("PUT /api/v3/repos/{owner}/{repo}/contents/{path...}", s.handlePutContents)
+	mux.HandleFunc("GET /api/v3/repos/{owner}/{repo}/pulls", s.handleListPulls)
+	mux.HandleFunc("POST /api/v3/repos/{owner}/{repo}/pulls", s.handleCreatePull)
+	mux.HandleFunc("PATCH /api/v3/repos/{owner}/{repo}/pulls/{number}", s.handleEditPull)
+	mux.HandleFunc("POST /api/v3/repos/{owner}/{repo}/issues/{number}/labels", s.handleAddLabels)
+	mux.HandleFunc("POST /api/graphql", s.handleGraphQL)
+
+	s.Server = start(conditional(mux))
+	return s, nil
+}
+
+// conditional adds ETags to successful GET responses and answers requests
+// whose If-None-Match still matches with 304 Not Modified, like GitHub
+func conditional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, req)
+
+		for name, values := range rec.Header() {
+			w.Header()[name] = values
+		}
+		if rec.Code == http.StatusOK {
+			sum := sha1.Sum(rec.Body.Bytes()) //nolint:gosec // only used to detect changes
+			etag := `"` + hex.EncodeToString(sum[:]) + `"`
+			w.Header().Set("ETag", etag)
+			if req.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	})
+}
+
+// NewClient returns a GitHub client talking to the fake server
+func (s *Server) NewClient() *github.Client {
+	client, err := github.NewEnterpriseClient(s.URL, s.URL, s.Client())
+	if err != nil {
+		// The URL comes from httptest and is always valid
+		panic(err)
+	}
+	return client
+}
+
+// Commits returns the commits made to a repository, in order
+func (s *Server) Commits(repo string) []Commit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	if !ok {
+		return nil
+	}
+	return append([]Commit(nil), r.commits...)
+}
+
+// PullRequests returns the pull requests opened in a repository, in order
+func (s *Server) PullRequests(repo string) []PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	if !ok {
+		return nil
+	}
+	pulls := make([]PullRequest, 0, len(r.pulls))
+	for _, pr := range r.pulls {
+		copied := *pr
+		copied.Labels = append([]string(nil), pr.Labels...)
+		pulls = append(pulls, copied)
+	}
+	return pulls
+}
+
+// OpenPullRequest adds an open pull request to a repository, as if someone
+// opened it by hand, and returns its number. Its head branch is created from
+// the default branch if the repository does not have it, also for pull
+// requests from forks.
+func (s *Server) OpenPullRequest(repo string, pr PullRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	if !ok {
+		return 0, fmt.Errorf("unknown repository %s", repo)
+	}
+	if r.branches[pr.Head] == nil {
+		base := r.branches[r.meta.DefaultBranch]
+		files := make(map[string][]byte, len(base.files))
+		for name, content := range base.files {
+			files[name] = content
+		}
+		r.branches[pr.Head] = &branch{sha: s.nextSHA(), files: files}
+	}
+	if pr.Base == "" {
+		pr.Base = r.meta.DefaultBranch
+	}
+
+	opened := pr
+	opened.Number = len(r.pulls) + 1
+	opened.State = "open"
+	opened.Labels = append([]string(nil), pr.Labels...)
+	r.pulls = append(r.pulls, &opened)
+	return opened.Number, nil
+}
+
+// SetTopics replaces the topics of a repository, as if someone edited them
+func (s *Server) SetTopics(repo string, topics []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	if !ok {
+		return fmt.Errorf("unknown repository %s", repo)
+	}
+	r.meta.Topics = append([]string(nil), topics...)
+	return nil
+}
+
+// HasBranch reports whether a repository has a branch
+func (s *Server) HasBranch(repo, branchName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	return ok && r.branches[branchName] != nil
+}
+
+// PutFile changes a file on a branch out of band, as if someone pushed or
+// merged a change. It is not recorded in Commits.
+func (s *Server) PutFile(repo, branchName, filePath string, content []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	if !ok {
+		return fmt.Errorf("unknown repository %s", repo)
+	}
+	b, ok := r.branches[branchName]
+	if !ok {
+		return fmt.Errorf("unknown branch %s", branchName)
+	}
+	b.files[filePath] = content
+	b.sha = s.nextSHA()
+	return nil
+}
+
+// RemoveFile deletes a file from a branch out of band. It is not recorded in
+// Commits.
+func (s *Server) RemoveFile(repo, branchName, filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	if !ok {
+		return fmt.Errorf("unknown repository %s", repo)
+	}
+	b, ok := r.branches[branchName]
+	if !ok {
+		return fmt.Errorf("unknown branch %s", branchName)
+	}
+	delete(b.files, filePath)
+	b.sha = s.nextSHA()
+	return nil
+}
+
+// File returns the content of a file on a branch of a repository
+func (s *Server) File(repo, branchName, filePath string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repo]
+	if !ok {
+		return nil, false
+	}
+	b, ok := r.branches[branchName]
+	if !ok {
+		return nil, false
+	}
+	content, ok := b.files[filePath]
+	return content, ok
+}
+
+// loadFixtures reads one repository per subdirectory of dir
+func (s *Server) loadFixtures(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		repoDir := filepath.Join(dir, entry.Name())
+		r := &repository{
+			name:     entry.Name(),
+			meta:     RepoMetadata{DefaultBranch: "main"},
+			branches: make(map[string]*branch),
+		}
+
+		if data, err := os.ReadFile(filepath.Join(repoDir, MetadataFile)); err == nil {
+			if err := json.Unmarshal(data, &r.meta); err != nil {
+				return fmt.Errorf("failed to parse %s metadata: %w", entry.Name(), err)
+			}
+			if r.meta.DefaultBranch == "" {
+				r.meta.DefaultBranch = "main"
+			}
+		}
+
+		files := make(map[string][]byte)
+		err := filepath.WalkDir(repoDir, func(p string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(repoDir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if rel == MetadataFile {
+				return nil
+			}
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files[rel] = content
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load fixture %s: %w", entry.Name(), err)
+		}
+
+		r.branches[r.meta.DefaultBranch] = &branch{sha: s.nextSHA(), files: files}
+		s.repos[r.name] = r
+	}
+
+	return nil
+}
+
+func (s *Server) handleListRepos(w http.ResponseWriter, req *http.Request) {
+	if req.PathValue("org") != s.org {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	names := make([]string, 0, len(s.repos))
+	for name := range s.repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	repos := make([]*github.Repository, 0, len(names))
+	for _, name := range names {
+		repos = append(repos, s.repoJSON(s.repos[name]))
+	}
+	s.mu.Unlock()
+
+	page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(req.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = 30
+	}
+
+	start := (page - 1) * perPage
+	if start > len(repos) {
+		start = len(repos)
+	}
+	end := start + perPage
+	if end > len(repos) {
+		end = len(repos)
+	}
+
+	if end < len(repos) {
+		next := *req.URL
+		q := next.Query()
+		q.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, s.URL, next.RequestURI()))
+	}
+
+	writeJSON(w, http.StatusOK, repos[start:end])
+}
+
+func (s *Server) handleGetRepo(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.repoJSON(r))
+}
+
+func (s *Server) handleGetTree(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	b := r.resolve(req.PathValue("sha"))
+	if b == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	recursive := req.URL.Query().Get("recursive") != ""
+	paths := make([]string, 0, len(b.files))
+	for p := range b.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var entries []*github.TreeEntry
+	seenDirs := make(map[string]bool)
+	for _, p := range paths {
+		if !recursive && strings.Contains(p, "/") {
+			dir := strings.SplitN(p, "/", 2)[0]
+			if !seenDirs[dir] {
+				seenDirs[dir] = true
+				entries = append(entries, treeEntry(dir, "tree", nil))
+			}
+			continue
+		}
+		for dir := path.Dir(p); recursive && dir != "."; dir = path.Dir(dir) {
+			if !seenDirs[dir] {
+				seenDirs[dir] = true
+				entries = append(entries, treeEntry(dir, "tree", nil))
+			}
+		}
+		entries = append(entries, treeEntry(p, "blob", b.files[p]))
+	}
+
+	writeJSON(w, http.StatusOK, &github.Tree{
+		SHA:       github.String(b.sha),
+		Entries:   entries,
+		Truncated: github.Bool(false),
+	})
+}
+
+func (s *Server) handleGetRef(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	name, ok := strings.CutPrefix(req.PathValue("ref"), "heads/")
+	b := r.branches[name]
+	if !ok || b == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, refJSON(name, b))
+}
+
+func (s *Server) handleCreateRef(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	name, ok := strings.CutPrefix(body.Ref, "refs/heads/")
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Reference name must start with refs/heads/")
+		return
+	}
+	if _, exists := r.branches[name]; exists {
+		writeError(w, http.StatusUnprocessableEntity, "Reference already exists")
+		return
+	}
+
+	source := r.resolve(body.SHA)
+	if source == nil {
+		writeError(w, http.StatusUnprocessableEntity, "Object does not exist")
+		return
+	}
+
+	files := make(map[string][]byte, len(source.files))
+	for p, content := range source.files {
+		files[p] = content
+	}
+	b := &branch{sha: source.sha, files: files}
+	r.branches[name] = b
+
+	writeJSON(w, http.StatusCreated, refJSON(name, b))
+}
+
+func (s *Server) handleUpdateRef(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		SHA   string `json:"sha"`
+		Force bool   `json:"force"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	name, ok := strings.CutPrefix(req.PathValue("ref"), "heads/")
+	b := r.branches[name]
+	if !ok || b == nil {
+		writeError(w, http.StatusUnprocessableEntity, "Reference does not exist")
+		return
+	}
+
+	source := r.resolve(body.SHA)
+	if source == nil {
+		writeError(w, http.StatusUnprocessableEntity, "Object does not exist")
+		return
+	}
+
+	// Ancestry is not modelled, so every update behaves like a forced one
+	files := make(map[string][]byte, len(source.files))
+	for p, content := range source.files {
+		files[p] = content
+	}
+	b.sha = source.sha
+	b.files = files
+
+	writeJSON(w, http.StatusOK, refJSON(name, b))
+}
+
+func (s *Server) handleDeleteRef(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	name, ok := strings.CutPrefix(req.PathValue("ref"), "heads/")
+	if !ok || r.branches[name] == nil || name == r.meta.DefaultBranch {
+		writeError(w, http.StatusUnprocessableEntity, "Reference does not exist")
+		return
+	}
+	delete(r.branches, name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetContents(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	ref := req.URL.Query().Get("ref")
+	if ref == "" {
+		ref = r.meta.DefaultBranch
+	}
+	b := r.resolve(ref)
+	if b == nil {
+		writeError(w, http.StatusNotFound, "No commit found for the ref "+ref)
+		return
+	}
+
+	filePath := req.PathValue("path")
+	content, ok := b.files[filePath]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contentJSON(filePath, content))
+}
+
+func (s *Server) handlePutContents(w http.ResponseWriter, req *http.Request) {
+	var body github.RepositoryContentFileOptions
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	branchName := body.GetBranch()
+	if branchName == "" {
+		branchName = r.meta.DefaultBranch
+	}
+	b := r.branches[branchName]
+	if b == nil {
+		writeError(w, http.StatusNotFound, "Branch "+branchName+" not found")
+		return
+	}
+
+	filePath := req.PathValue("path")
+	if existing, ok := b.files[filePath]; ok {
+		if body.GetSHA() == "" {
+			writeError(w, http.StatusUnprocessableEntity, `Invalid request. "sha" wasn't supplied.`)
+			return
+		}
+		if body.GetSHA() != blobSHA(existing) {
+			writeError(w, http.StatusConflict, fmt.Sprintf("%s does not match %s", filePath, body.GetSHA()))
+			return
+		}
+	}
+
+	b.files[filePath] = body.Content
+	b.sha = s.nextSHA()
+	r.commits = append(r.commits, Commit{
+		SHA:     b.sha,
+		Branch:  branchName,
+		Path:    filePath,
+		Message: body.GetMessage(),
+		Content: body.Content,
+	})
+
+	writeJSON(w, http.StatusOK, &github.RepositoryContentResponse{
+		Content: contentJSON(filePath, body.Content),
+		Commit:  github.Commit{SHA: github.String(b.sha), Message: body.Message},
+	})
+}
+
+func (s *Server) handleListPulls(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	state := req.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	head := req.URL.Query().Get("head")
+
+	pulls := []*github.PullRequest{}
+	for _, pr := range r.pulls {
+		if state != "all" && pr.State != state {
+			continue
+		}
+		if head != "" && head != s.org+":"+pr.Head {
+			continue
+		}
+		pulls = append(pulls, s.pullJSON(r, pr))
+	}
+
+	writeJSON(w, http.StatusOK, pulls)
+}
+
+func (s *Server) handleCreatePull(w http.ResponseWriter, req *http.Request) {
+	var body github.NewPullRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	if r.branches[body.GetHead()] == nil || r.branches[body.GetBase()] == nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+
+	pr := &PullRequest{
+		Number: len(r.pulls) + 1,
+		Title:  body.GetTitle(),
+		Body:   body.GetBody(),
+		Head:   body.GetHead(),
+		Base:   body.GetBase(),
+		State:  "open",
+	}
+	r.pulls = append(r.pulls, pr)
+
+	writeJSON(w, http.StatusCreated, s.pullJSON(r, pr))
+}
+
+func (s *Server) handleEditPull(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		State *string `json:"state"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	pr := r.pull(req.PathValue("number"))
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if body.Title != nil {
+		pr.Title = *body.Title
+	}
+	if body.Body != nil {
+		pr.Body = *body.Body
+	}
+	if body.State != nil {
+		pr.State = *body.State
+	}
+
+	writeJSON(w, http.StatusOK, s.pullJSON(r, pr))
+}
+
+func (s *Server) handleAddLabels(w http.ResponseWriter, req *http.Request) {
+	var labels []string
+	if err := json.NewDecoder(req.Body).Decode(&labels); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.lookup(w, req)
+	if r == nil {
+		return
+	}
+
+	pr := r.pull(req.PathValue("number"))
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	result := []*github.Label{}
+	for _, label := range labels {
+		found := false
+		for _, existing := range pr.Labels {
+			if existing == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			pr.Labels = append(pr.Labels, label)
+		}
+	}
+	for _, label := range pr.Labels {
+		result = append(result, &github.Label{Name: github.String(label)})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleGraphQL answers the batched repository snapshot query. Rather than
+// parsing the query, it answers alias r<i> for every variable n<i> naming a
+// repository, with all the fields the snapshot query selects.
+func (s *Server) handleGraphQL(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Query     string            `json:"query"`
+		Variables map[string]string `json:"variables"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make(map[string]interface{})
+	var errs []map[string]interface{}
+	for name, value := range body.Variables {
+		index, ok := strings.CutPrefix(name, "n")
+		if !ok {
+			continue
+		}
+		alias := "r" + index
+
+		r, ok := s.repos[value]
+		if !ok || body.Variables["owner"] != s.org {
+			data[alias] = nil
+			errs = append(errs, map[string]interface{}{
+				"type":    "NOT_FOUND",
+				"path":    []string{alias},
+				"message": fmt.Sprintf("Could not resolve to a Repository with the name '%s/%s'.", body.Variables["owner"], value),
+			})
+			continue
+		}
+		data[alias] = s.repoGraphQL(r)
+	}
+
+	result := map[string]interface{}{"data": data}
+	if len(errs) > 0 {
+		result["errors"] = errs
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// repoGraphQL renders a repository as selected by the snapshot query. The
+// caller must hold s.mu.
+func (s *Server) repoGraphQL(r *repository) map[string]interface{} {
+	b := r.branches[r.meta.DefaultBranch]
+
+	topics := []interface{}{}
+	for _, topic := range r.meta.Topics {
+		topics = append(topics, map[string]interface{}{"topic": map[string]string{"name": topic}})
+	}
+
+	var language interface{}
+	if r.meta.Language != "" {
+		language = map[string]string{"name": r.meta.Language}
+	}
+
+	return map[string]interface{}{
+		"name":             r.name,
+		"url":              fmt.Sprintf("%s/%s/%s", s.URL, s.org, r.name),
+		"isArchived":       r.meta.Archived,
+		"visibility":       strings.ToUpper(r.meta.visibility()),
+		"primaryLanguage":  language,
+		"defaultBranchRef": map[string]interface{}{"name": r.meta.DefaultBranch, "target": map[string]string{"oid": b.sha}},
+		"repositoryTopics": map[string]interface{}{"nodes": topics},
+		"configYml":        blobGraphQL(b, ".github/dependabot.yml"),
+		"configYaml":       blobGraphQL(b, ".github/dependabot.yaml"),
+		"root":             treeGraphQL(b, ""),
+		"dotGithub":        treeGraphQL(b, ".github"),
+		"workflows":        treeGraphQL(b, ".github/workflows"),
+	}
+}
+
+// blobGraphQL renders a file as a Blob object, or nil if it does not exist
+func blobGraphQL(b *branch, filePath string) interface{} {
+	content, ok := b.files[filePath]
+	if !ok {
+		return nil
+	}
+	return map[string]string{"oid": blobSHA(content), "text": string(content)}
+}
+
+// treeGraphQL renders the direct entries of a directory as a Tree object,
+// or nil if the directory does not exist
+func treeGraphQL(b *branch, dir string) interface{} {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	seen := make(map[string]string)
+	for p := range b.files {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+		if name, _, nested := strings.Cut(rest, "/"); nested {
+			seen[name] = "tree"
+		} else {
+			seen[name] = "blob"
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, map[string]string{"name": name, "type": seen[name]})
+	}
+	return map[string]interface{}{"entries": entries}
+}
+
+// lookup resolves the repository addressed by the request, writing a 404
+// when it is unknown. The caller must hold s.mu.
+func (s *Server) lookup(w http.ResponseWriter, req *http.Request) *repository {
+	r, ok := s.repos[req.PathValue("repo")]
+	if !ok || req.PathValue("owner") != s.org {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil
+	}
+	return r
+}
+
+func (s *Server) repoJSON(r *repository) *github.Repository {
+	return &github.Repository{
+		Name:          github.String(r.name),
+		FullName:      github.String(s.org + "/" + r.name),
+		HTMLURL:       github.String(fmt.Sprintf("%s/%s/%s", s.URL, s.org, r.name)),
+		DefaultBranch: github.String(r.meta.DefaultBranch),
+		Topics:        r.meta.Topics,
+		Archived:      github.Bool(r.meta.Archived),
+		Private:       github.Bool(r.meta.Private),
+		Visibility:    github.String(r.meta.visibility()),
+		Language:      github.String(r.meta.Language),
+	}
+}
+
+// visibility returns the visibility of the repository as the REST API
+// reports it
+func (m RepoMetadata) visibility() string {
+	if m.Private {
+		return "private"
+	}
+	return "public"
+}
+
+// nextSHA returns a fresh commit SHA. The caller must hold s.mu or be
+// running before the server starts.
+func (s *Server) nextSHA() string {
+	s.seq++
+	sum := sha1.Sum([]byte(fmt.Sprintf("commit %d", s.seq))) //nolint:gosec // git object IDs are SHA-1
+	return hex.EncodeToString(sum[:])
+}
+
+// resolve finds a branch by name, by head SHA, or "HEAD" for the default branch
+func (r *repository) resolve(ref string) *branch {
+	if ref == "HEAD" {
+		ref = r.meta.DefaultBranch
+	}
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	if b, ok := r.branches[ref]; ok {
+		return b
+	}
+	for _, b := range r.branches {
+		if b.sha == ref {
+			return b
+		}
+	}
+	return nil
+}
+
+func refJSON(name string, b *branch) *github.Reference {
+	return &github.Reference{
+		Ref: github.String("refs/heads/" + name),
+		Object: &github.GitObject{
+			Type: github.String("commit"),
+			SHA:  github.String(b.sha),
+		},
+	}
+}
+
+func contentJSON(filePath string, content []byte) *github.RepositoryContent {
+	return &github.RepositoryContent{
+		Type:     github.String("file"),
+		Name:     github.String(path.Base(filePath)),
+		Path:     github.String(filePath),
+		Encoding: github.String("base64"),
+		Content:  github.String(base64.StdEncoding.EncodeToString(content)),
+		Size:     github.Int(len(content)),
+		SHA:      github.String(blobSHA(content)),
+	}
+}
+
+func (s *Server) pullJSON(r *repository, pr *PullRequest) *github.PullRequest {
+	labels := make([]*github.Label, 0, len(pr.Labels))
+	for _, label := range pr.Labels {
+		labels = append(labels, &github.Label{Name: github.String(label)})
+	}
+
+	headRepo := pr.HeadRepo
+	if headRepo == "" {
+		headRepo = s.org + "/" + r.name
+	}
+
+	return &github.PullRequest{
+		Number:  github.Int(pr.Number),
+		State:   github.String(pr.State),
+		Title:   github.String(pr.Title),
+		Body:    github.String(pr.Body),
+		HTMLURL: github.String(fmt.Sprintf("%s/%s/%s/pull/%d", s.URL, s.org, r.name, pr.Number)),
+		Head: &github.PullRequestBranch{
+			Ref:  github.String(pr.Head),
+			Repo: &github.Repository{FullName: github.String(headRepo)},
+		},
+		Base:   &github.PullRequestBranch{Ref: github.String(pr.Base)},
+		Labels: labels,
+	}
+}
+
+// pull finds a pull request by its number as given in a URL path
+func (r *repository) pull(number string) *PullRequest {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > len(r.pulls) {
+		return nil
+	}
+	return r.pulls[n-1]
+}
+
+func treeEntry(p, entryType string, content []byte) *github.TreeEntry {
+	entry := &github.TreeEntry{
+		Path: github.String(p),
+		Type: github.String(entryType),
+		Mode: github.String("040000"),
+	}
+	if entryType == "blob" {
+		entry.Mode = github.String("100644")
+		entry.SHA = github.String(blobSHA(content))
+		entry.Size = github.Int(len(content))
+	}
+	return entry
+}
+
+// blobSHA computes the git blob object ID of content
+func blobSHA(content []byte) string {
+	h := sha1.New() //nolint:gosec // git object IDs are SHA-1
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
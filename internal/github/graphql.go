@@ -0,0 +1,296 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/google/go-github/v50/github"
+	"gopkg.in/yaml.v3"
+)
+
+// snapshotBatchSize is the number of repositories fetched per GraphQL query
+const snapshotBatchSize = 25
+
+// RepositorySnapshot is what a batched GraphQL query tells about a
+// repository: its metadata, the existing Dependabot configuration and the
+// files at the root of the default branch
+type RepositorySnapshot struct {
+	Name          string
+	URL           string
+	DefaultBranch string
+	HeadSHA       string
+	Archived      bool
+	Topics        []string
+	// Visibility is public, private or internal
+	Visibility string
+	// Language is the primary language, empty if unknown
+	Language string
+
+	// Paths lists the files at the root and in .github/workflows
+	Paths []string
+	// Complete is set when Paths lists every file of the repository: the
+	// root has no directories besides .github, and .github none besides
+	// workflows
+	Complete bool
+
+	// ConfigContent is the existing dependabot.yml or dependabot.yaml, nil
+	// if there is none
+	ConfigContent []byte
+	// ConfigPath is where ConfigContent was found, ConfigPath if nowhere
+	ConfigPath string
+	// ConfigIncomplete is set when the configuration exists but GraphQL did
+	// not return its text, e.g. because it is too large
+	ConfigIncomplete bool
+}
+
+// Repository converts the snapshot into the REST representation of the
+// repository
+func (s *RepositorySnapshot) Repository() *github.Repository {
+	return &github.Repository{
+		Name:          github.String(s.Name),
+		HTMLURL:       github.String(s.URL),
+		DefaultBranch: github.String(s.DefaultBranch),
+		Archived:      github.Bool(s.Archived),
+		Topics:        s.Topics,
+		Visibility:    github.String(s.Visibility),
+		Private:       github.Bool(s.Visibility != "public"),
+		Language:      github.String(s.Language),
+	}
+}
+
+// ExistingConfig parses the configuration found in the snapshot like
+// GetExistingConfig does
+func (s *RepositorySnapshot) ExistingConfig() (*config.DependabotConfig, []byte, string, error) {
+	if s.ConfigContent == nil {
+		return nil, nil, s.ConfigPath, nil
+	}
+
+	var cfg config.DependabotConfig
+	if err := yaml.Unmarshal(s.ConfigContent, &cfg); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to parse existing config: %w", err)
+	}
+	return &cfg, s.ConfigContent, s.ConfigPath, nil
+}
+
+// GetSnapshots fetches snapshots of the named repositories through the
+// GraphQL API, many repositories per request. Repositories that cannot be
+// read are missing from the result.
+func (c *Client) GetSnapshots(ctx context.Context, repos []string) (map[string]*RepositorySnapshot, error) {
+	snapshots := make(map[string]*RepositorySnapshot, len(repos))
+
+	for start := 0; start < len(repos); start += snapshotBatchSize {
+		batch := repos[start:min(start+snapshotBatchSize, len(repos))]
+		if err := c.getSnapshotBatch(ctx, batch, snapshots); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshots, nil
+}
+
+// graphQLRepository is the shape of a repository in snapshotQuery results
+type graphQLRepository struct {
+	Name            string `json:"name"`
+	URL             string `json:"url"`
+	IsArchived      bool   `json:"isArchived"`
+	Visibility      string `json:"visibility"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	DefaultBranchRef *struct {
+		Name   string `json:"name"`
+		Target struct {
+			OID string `json:"oid"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	ConfigYml  *graphQLBlob `json:"configYml"`
+	ConfigYaml *graphQLBlob `json:"configYaml"`
+	Root       *graphQLTree `json:"root"`
+	DotGithub  *graphQLTree `json:"dotGithub"`
+	Workflows  *graphQLTree `json:"workflows"`
+}
+
+type graphQLBlob struct {
+	OID  string  `json:"oid"`
+	Text *string `json:"text"`
+}
+
+type graphQLTree struct {
+	Entries []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"entries"`
+}
+
+// snapshotFields selects the snapshot of one repository
+const snapshotFields = `
+    name
+    url
+    isArchived
+    visibility
+    primaryLanguage { name }
+    defaultBranchRef { name target { oid } }
+    repositoryTopics(first: 100) { nodes { topic { name } } }
+    configYml: object(expression: "HEAD:.github/dependabot.yml") { ... on Blob { oid text } }
+    configYaml: object(expression: "HEAD:.github/dependabot.yaml") { ... on Blob { oid text } }
+    root: object(expression: "HEAD:") { ... on Tree { entries { name type } } }
+    dotGithub: object(expression: "HEAD:.github") { ... on Tree { entries { name type } } }
+    workflows: object(expression: "HEAD:.github/workflows") { ... on Tree { entries { name type } } }`
+
+// snapshotQuery builds a query fetching n repositories aliased r0 to r<n-1>,
+// named by the variables n0 to n<n-1>
+func snapshotQuery(n int) string {
+	var sb strings.Builder
+	sb.WriteString("query($owner: String!")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, ", $n%d: String!", i)
+	}
+	sb.WriteString(") {")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "\n  r%d: repository(owner: $owner, name: $n%d) {%s\n  }", i, i, snapshotFields)
+	}
+	sb.WriteString("\n}\n")
+	return sb.String()
+}
+
+// getSnapshotBatch fetches the snapshots of repos with a single query
+func (c *Client) getSnapshotBatch(ctx context.Context, repos []string, snapshots map[string]*RepositorySnapshot) error {
+	variables := map[string]interface{}{"owner": c.org}
+	for i, name := range repos {
+		variables[fmt.Sprintf("n%d", i)] = name
+	}
+
+	var result struct {
+		Data   map[string]*graphQLRepository `json:"data"`
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	err := c.graphQL(ctx, snapshotQuery(len(repos)), variables, &result)
+	if err != nil {
+		return fmt.Errorf("failed to query repositories: %w", err)
+	}
+
+	// Unknown or inaccessible repositories come back as null with a
+	// NOT_FOUND error; anything else fails the whole batch
+	for _, e := range result.Errors {
+		if e.Type != "NOT_FOUND" {
+			return fmt.Errorf("failed to query repositories: %s", e.Message)
+		}
+	}
+
+	for i := range repos {
+		repo := result.Data[fmt.Sprintf("r%d", i)]
+		if repo == nil || repo.DefaultBranchRef == nil {
+			// Missing or empty repositories are left to the REST API
+			continue
+		}
+		snapshots[repo.Name] = repo.snapshot()
+	}
+
+	return nil
+}
+
+// snapshot converts a query result into a RepositorySnapshot
+func (r *graphQLRepository) snapshot() *RepositorySnapshot {
+	s := &RepositorySnapshot{
+		Name:          r.Name,
+		URL:           r.URL,
+		DefaultBranch: r.DefaultBranchRef.Name,
+		HeadSHA:       r.DefaultBranchRef.Target.OID,
+		Archived:      r.IsArchived,
+		Visibility:    strings.ToLower(r.Visibility),
+	}
+	if r.PrimaryLanguage != nil {
+		s.Language = r.PrimaryLanguage.Name
+	}
+
+	for _, node := range r.RepositoryTopics.Nodes {
+		s.Topics = append(s.Topics, node.Topic.Name)
+	}
+
+	s.Complete = true
+	if r.Root != nil {
+		for _, entry := range r.Root.Entries {
+			switch {
+			case entry.Type == "blob":
+				s.Paths = append(s.Paths, entry.Name)
+			case entry.Type == "tree" && entry.Name != ".github":
+				s.Complete = false
+			}
+		}
+	}
+	if r.DotGithub != nil {
+		for _, entry := range r.DotGithub.Entries {
+			if entry.Type == "tree" && entry.Name != "workflows" {
+				s.Complete = false
+			}
+		}
+	}
+	if r.Workflows != nil {
+		for _, entry := range r.Workflows.Entries {
+			switch entry.Type {
+			case "blob":
+				s.Paths = append(s.Paths, ".github/workflows/"+entry.Name)
+			case "tree":
+				s.Complete = false
+			}
+		}
+	}
+
+	// dependabot.yml takes precedence, as in GetExistingConfig
+	s.ConfigPath = ConfigPath
+	blob := r.ConfigYml
+	if blob == nil || blob.OID == "" {
+		blob = r.ConfigYaml
+		if blob != nil && blob.OID != "" {
+			s.ConfigPath = ConfigPaths[1]
+		}
+	}
+	if blob != nil && blob.OID != "" {
+		if blob.Text == nil {
+			s.ConfigIncomplete = true
+		} else {
+			s.ConfigContent = []byte(*blob.Text)
+		}
+	}
+
+	return s
+}
+
+// graphQL posts a query to the GraphQL API and decodes the response into v
+func (c *Client) graphQL(ctx context.Context, query string, variables map[string]interface{}, v interface{}) error {
+	body := map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	}
+
+	return c.retry.do(ctx, func() error {
+		req, err := c.client.NewRequest("POST", graphQLURL(c.client.BaseURL), body)
+		if err != nil {
+			return err
+		}
+		_, err = c.client.Do(ctx, req, v)
+		return err
+	})
+}
+
+// graphQLURL derives the GraphQL endpoint from the REST API URL: it is
+// /graphql on github.com and /api/graphql on Enterprise Server
+func graphQLURL(base *url.URL) string {
+	if strings.HasSuffix(base.Path, "/api/v3/") {
+		return base.ResolveReference(&url.URL{Path: "../graphql"}).String()
+	}
+	return base.ResolveReference(&url.URL{Path: "graphql"}).String()
+}
@@ -0,0 +1,98 @@
+package github
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/github/githubtest"
+)
+
+func TestGraphQLURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{base: "https://api.github.com/", want: "https://api.github.com/graphql"},
+		{base: "https://github.example.com/api/v3/", want: "https://github.example.com/api/graphql"},
+	}
+
+	for _, tt := range tests {
+		base, err := url.Parse(tt.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := graphQLURL(base); got != tt.want {
+			t.Errorf("graphQLURL(%s) = %s, want %s", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestClient_GetSnapshots(t *testing.T) {
+	fixtures := t.TempDir()
+	for name, content := range map[string]string{
+		"web-app/package.json":               "{}",
+		"web-app/Dockerfile":                 "FROM node",
+		"web-app/.github/workflows/ci.yml":   "on: push",
+		"web-app/src/index.js":               "",
+		"api-service/.fixture.json":          `{"default_branch": "develop", "topics": ["backend"], "private": true, "language": "Go"}`,
+		"api-service/go.mod":                 "module api",
+		"api-service/.github/dependabot.yml": "version: 2\nupdates:\n  - package-ecosystem: gomod\n    directory: /\n",
+	} {
+		path := filepath.Join(fixtures, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv, err := githubtest.NewServer("acme", fixtures)
+	if err != nil {
+		t.Fatalf("failed to start fake GitHub server: %v", err)
+	}
+	defer srv.Close()
+
+	client := NewClientFromGitHub(srv.NewClient(), "acme")
+	snapshots, err := client.GetSnapshots(context.Background(), []string{"web-app", "api-service", "missing"})
+	if err != nil {
+		t.Fatalf("GetSnapshots() error = %v", err)
+	}
+
+	if len(snapshots) != 2 || snapshots["missing"] != nil {
+		t.Fatalf("expected snapshots of the two existing repositories, got %v", snapshots)
+	}
+
+	web := snapshots["web-app"]
+	if web.DefaultBranch != "main" || web.HeadSHA == "" || web.ConfigContent != nil || web.Visibility != "public" || web.Language != "" {
+		t.Errorf("web-app: unexpected snapshot %+v", web)
+	}
+	if want := []string{"Dockerfile", "package.json", ".github/workflows/ci.yml"}; !slices.Equal(web.Paths, want) {
+		t.Errorf("web-app: expected root and workflow files %v, got %v", want, web.Paths)
+	}
+	if web.Complete {
+		t.Errorf("web-app: expected src to be missing from the snapshot")
+	}
+
+	api := snapshots["api-service"]
+	if api.DefaultBranch != "develop" || !slices.Contains(api.Topics, "backend") {
+		t.Errorf("api-service: unexpected snapshot %+v", api)
+	}
+	if !api.Complete {
+		t.Errorf("api-service: expected the root files to be all files")
+	}
+	if repo := api.Repository(); repo.GetVisibility() != "private" || !repo.GetPrivate() || repo.GetLanguage() != "Go" {
+		t.Errorf("api-service: expected a private Go repository, got %+v", repo)
+	}
+	cfg, _, path, err := api.ExistingConfig()
+	if err != nil || cfg == nil || len(cfg.Updates) != 1 {
+		t.Errorf("api-service: expected the existing config, got %+v (%v)", cfg, err)
+	}
+	if path != ConfigPath {
+		t.Errorf("api-service: expected the config at %s, got %s", ConfigPath, path)
+	}
+}
@@ -0,0 +1,311 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/google/go-github/v50/github"
+)
+
+// SyncBranch is the branch configuration pull requests are opened from. It is
+// stable so later runs update the open pull request instead of adding another.
+const SyncBranch = "dependabot-config-sync"
+
+// SyncLabel marks pull requests opened by the synchronizer
+const SyncLabel = "dependabot-config"
+
+// legacySyncBranch matches the per-run branches of earlier versions, which
+// were named dependabot-config-<unix timestamp>
+var legacySyncBranch = regexp.MustCompile(`^dependabot-config-\d+$`)
+
+// PullRequestResult describes the sync pull request after CreatePullRequest
+type PullRequestResult struct {
+	Number int
+	URL    string
+	// Updated is true when an already open sync pull request was reused
+	Updated bool
+}
+
+// CreatePullRequest proposes the Dependabot configuration through a pull
+// request. When a sync pull request is already open, its branch is reset
+// onto the default branch with the new configuration and its description is
+// refreshed; duplicate sync pull requests left by earlier runs are closed.
+// Only pull requests from sync branches of the repository itself are reused
+// or closed; those that merely carry the sync label or come from a fork are
+// left alone.
+// content is the rendered file written to path, config is used for the
+// description.
+func (c *Client) CreatePullRequest(ctx context.Context, repo, path string, config *config.DependabotConfig, content []byte) (*PullRequestResult, error) {
+	defaultBranch, err := c.getDefaultBranch(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := c.listSyncPullRequests(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep one sync pull request and close any duplicates
+	var existing *github.PullRequest
+	for _, pr := range open {
+		if existing == nil || pr.GetHead().GetRef() == SyncBranch {
+			existing = pr
+		}
+	}
+	for _, pr := range open {
+		if pr != existing {
+			if err := c.closePullRequest(ctx, repo, pr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	branchName := SyncBranch
+	if existing != nil {
+		branchName = existing.GetHead().GetRef()
+	}
+
+	// Only push when the branch does not already carry the desired config
+	current, _, err := c.getFileContentAt(ctx, repo, path, branchName)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil || !bytes.Equal(current, content) {
+		if err := c.pushConfig(ctx, repo, path, defaultBranch, branchName, content); err != nil {
+			return nil, err
+		}
+	}
+
+	prBody := generatePRBody(config)
+
+	if existing != nil {
+		if existing.GetBody() != prBody {
+			err := c.retry.do(ctx, func() error {
+				_, _, err := c.client.PullRequests.Edit(ctx, c.org, repo, existing.GetNumber(), &github.PullRequest{
+					Body: &prBody,
+				})
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to update pull request: %w", err)
+			}
+		}
+
+		return &PullRequestResult{
+			Number:  existing.GetNumber(),
+			URL:     existing.GetHTMLURL(),
+			Updated: true,
+		}, nil
+	}
+
+	// Create pull request
+	prTitle := "Configure Dependabot for dependency updates"
+
+	pr := &github.NewPullRequest{
+		Title:               &prTitle,
+		Head:                &branchName,
+		Base:                &defaultBranch,
+		Body:                &prBody,
+		MaintainerCanModify: github.Bool(true),
+	}
+
+	var created *github.PullRequest
+	err = c.retry.do(ctx, func() error {
+		var err error
+		created, _, err = c.client.PullRequests.Create(ctx, c.org, repo, pr)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull request: %w", err)
+	}
+
+	err = c.retry.do(ctx, func() error {
+		_, _, err := c.client.Issues.AddLabelsToIssue(ctx, c.org, repo, created.GetNumber(), []string{SyncLabel})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to label pull request: %w", err)
+	}
+
+	return &PullRequestResult{
+		Number: created.GetNumber(),
+		URL:    created.GetHTMLURL(),
+	}, nil
+}
+
+// CloseSyncPullRequest closes any open sync pull request and deletes its
+// branch. It reports whether a pull request was closed.
+func (c *Client) CloseSyncPullRequest(ctx context.Context, repo string) (bool, error) {
+	open, err := c.listSyncPullRequests(ctx, repo)
+	if err != nil {
+		return false, err
+	}
+
+	for _, pr := range open {
+		if err := c.closePullRequest(ctx, repo, pr); err != nil {
+			return false, err
+		}
+	}
+
+	return len(open) > 0, nil
+}
+
+// listSyncPullRequests finds the open pull requests opened by the
+// synchronizer, identified by a sync branch of the repository. The sync
+// label alone is not enough, anyone can add it to their pull request.
+func (c *Client) listSyncPullRequests(ctx context.Context, repo string) ([]*github.PullRequest, error) {
+	var found []*github.PullRequest
+
+	opt := &github.PullRequestListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		var prs []*github.PullRequest
+		var resp *github.Response
+		err := c.retry.do(ctx, func() error {
+			var err error
+			prs, resp, err = c.client.PullRequests.List(ctx, c.org, repo, opt)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests: %w", err)
+		}
+
+		for _, pr := range prs {
+			if c.ownsSyncBranch(repo, pr) {
+				found = append(found, pr)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return found, nil
+}
+
+// closePullRequest closes a sync pull request and deletes its branch
+func (c *Client) closePullRequest(ctx context.Context, repo string, pr *github.PullRequest) error {
+	err := c.retry.do(ctx, func() error {
+		_, _, err := c.client.PullRequests.Edit(ctx, c.org, repo, pr.GetNumber(), &github.PullRequest{
+			State: github.String("closed"),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to close pull request #%d: %w", pr.GetNumber(), err)
+	}
+
+	// Branch cleanup is best effort; a leftover branch is reset on the next push
+	_, _ = c.client.Git.DeleteRef(ctx, c.org, repo, "refs/heads/"+pr.GetHead().GetRef())
+
+	return nil
+}
+
+// pushConfig resets branch onto the head of base, creating it if needed, and
+// commits the configuration to path on it
+func (c *Client) pushConfig(ctx context.Context, repo, path, base, branch string, content []byte) error {
+	// Get reference of default branch
+	var ref *github.Reference
+	err := c.retry.do(ctx, func() error {
+		var err error
+		ref, _, err = c.client.Git.GetRef(ctx, c.org, repo, "refs/heads/"+base)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get reference: %w", err)
+	}
+
+	branchRef := &github.Reference{
+		Ref: github.String("refs/heads/" + branch),
+		Object: &github.GitObject{
+			SHA: ref.Object.SHA,
+		},
+	}
+
+	var resp *github.Response
+	err = c.retry.do(ctx, func() error {
+		var err error
+		_, resp, err = c.client.Git.CreateRef(ctx, c.org, repo, branchRef)
+		return err
+	})
+	if err != nil {
+		if resp == nil || resp.StatusCode != http.StatusUnprocessableEntity {
+			return fmt.Errorf("failed to create branch: %w", err)
+		}
+		// The branch already exists, force it back onto the default branch
+		err = c.retry.do(ctx, func() error {
+			_, _, err := c.client.Git.UpdateRef(ctx, c.org, repo, branchRef, true)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to reset branch: %w", err)
+		}
+	}
+
+	message := "Add/Update Dependabot configuration"
+	opts := &github.RepositoryContentFileOptions{
+		Message: &message,
+		Content: content,
+		Branch:  &branch,
+	}
+
+	// Check if file exists
+	existingContent, sha, err := c.getFileContentAt(ctx, repo, path, branch)
+	if err != nil {
+		return err
+	}
+	if existingContent != nil {
+		opts.SHA = &sha
+	}
+	err = c.retry.do(ctx, func() error {
+		_, _, err := c.client.Repositories.CreateFile(ctx, c.org, repo, path, opts)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create/update file in branch: %w", err)
+	}
+
+	return nil
+}
+
+// getDefaultBranch returns the default branch of a repository
+func (c *Client) getDefaultBranch(ctx context.Context, repo string) (string, error) {
+	var repoInfo *github.Repository
+	err := c.retry.do(ctx, func() error {
+		var err error
+		repoInfo, _, err = c.client.Repositories.Get(ctx, c.org, repo)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository info: %w", err)
+	}
+
+	defaultBranch := "main"
+	if repoInfo.DefaultBranch != nil {
+		defaultBranch = *repoInfo.DefaultBranch
+	}
+
+	return defaultBranch, nil
+}
+
+// ownsSyncBranch reports whether the head of pr is a sync branch in repo
+// itself, which the synchronizer may reset and delete. Branches of forks and
+// of pull requests that only carry the sync label are left alone.
+func (c *Client) ownsSyncBranch(repo string, pr *github.PullRequest) bool {
+	ref := pr.GetHead().GetRef()
+	if ref != SyncBranch && !legacySyncBranch.MatchString(ref) {
+		return false
+	}
+	return strings.EqualFold(pr.GetHead().GetRepo().GetFullName(), c.org+"/"+repo)
+}
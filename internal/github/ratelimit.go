@@ -0,0 +1,241 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitReserve is the primary budget kept back; below it requests
+	// wait for the limit to reset
+	rateLimitReserve = 50
+
+	// secondaryLimitWait is how long to back off from a secondary rate limit
+	// that does not say when to retry, as GitHub recommends
+	secondaryLimitWait = time.Minute
+
+	// maxRateLimitRetries is how often a rate limited request is retried
+	maxRateLimitRetries = 3
+
+	// recoverAfter is the number of successful responses after which a
+	// reduced concurrency is doubled again
+	recoverAfter = 50
+)
+
+// RateLimiter tracks the API budget reported in response headers. Its
+// transport waits when the budget runs low or GitHub asks to back off, and
+// it suggests how many repositories to process concurrently.
+type RateLimiter struct {
+	mu          sync.Mutex
+	limit       int
+	remaining   int
+	reset       time.Time
+	pausedUntil time.Time
+	scale       float64
+	successes   int
+
+	// OnWait, if set, is called before waiting for the rate limit
+	OnWait func(wait time.Duration, reason string)
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRateLimiter creates a rate limiter with an unknown budget
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		remaining: -1,
+		scale:     1,
+		now:       time.Now,
+		sleep:     sleepContext,
+	}
+}
+
+// Transport wraps base so requests honour the rate limit
+func (l *RateLimiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{limiter: l, base: base}
+}
+
+// Concurrency suggests how many repositories to process at once, at most
+// limit. It shrinks after secondary rate limits and when the budget runs
+// low, and recovers as requests succeed again.
+func (l *RateLimiter) Concurrency(limit int) int {
+	if l == nil {
+		return limit
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	width := int(float64(limit) * l.scale)
+	if l.limit > 0 && l.remaining >= 0 && l.remaining < l.limit/10 {
+		width = 1
+	}
+	return min(max(width, 1), limit)
+}
+
+// Remaining returns the primary rate limit budget left, or -1 if unknown
+func (l *RateLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.remaining
+}
+
+// wait blocks until a request may be sent
+func (l *RateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.now()
+	var wait time.Duration
+	var reason string
+	switch {
+	case l.pausedUntil.After(now):
+		wait, reason = l.pausedUntil.Sub(now), "secondary rate limit"
+	case l.remaining >= 0 && l.remaining <= rateLimitReserve && l.reset.After(now):
+		wait, reason = l.reset.Sub(now), "rate limit budget exhausted"
+	}
+	onWait := l.OnWait
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	if onWait != nil {
+		onWait(wait, reason)
+	}
+	return l.sleep(ctx, wait)
+}
+
+// update records the budget reported by a response and reports whether the
+// request was rate limited. The next wait then lasts until it may be retried.
+func (l *RateLimiter) update(resp *http.Response, body []byte) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit")); err == nil {
+		l.limit = limit
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		l.remaining = remaining
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		l.reset = time.Unix(reset, 0)
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		l.successes++
+		if l.scale < 1 && l.successes >= recoverAfter {
+			l.scale = min(l.scale*2, 1)
+			l.successes = 0
+		}
+		return false
+	}
+
+	var wait time.Duration
+	switch {
+	case resp.Header.Get("Retry-After") != "":
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			seconds = int(secondaryLimitWait.Seconds())
+		}
+		wait = time.Duration(seconds) * time.Second
+	case l.remaining == 0 && l.reset.After(now):
+		// Primary limit exhausted; wait honours the reset
+		l.successes = 0
+		return true
+	case bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit")):
+		wait = secondaryLimitWait
+	default:
+		// An ordinary permission error
+		return false
+	}
+
+	// Secondary limits mean too many concurrent requests: slow down
+	l.pausedUntil = now.Add(wait)
+	l.scale = max(l.scale/2, 0.01)
+	l.successes = 0
+	return true
+}
+
+// rateLimitTransport waits for the rate limit before sending requests and
+// retries requests that were rate limited
+type rateLimitTransport struct {
+	limiter *RateLimiter
+	base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var body []byte
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			body, err = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		limited := t.limiter.update(resp, body)
+		if !limited || attempt >= maxRateLimitRetries || !replayable(req) {
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// replayable reports whether a request can be sent again
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind returns a copy of req with a fresh body, ready to be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,210 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock lets the rate limiter sleep without waiting
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) sleep(_ context.Context, d time.Duration) error {
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newTestRateLimiter() (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	limiter := NewRateLimiter()
+	limiter.now = func() time.Time { return clock.now }
+	limiter.sleep = clock.sleep
+	return limiter, clock
+}
+
+// response is a canned reply of the fake API
+type response struct {
+	status    int
+	headers   map[string]string
+	body      string
+	remaining int
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []response
+		wantCode  int
+		wantCalls int
+		wantSlept []time.Duration
+		wantScale float64
+	}{
+		{
+			name:      "success",
+			responses: []response{{status: http.StatusOK, remaining: 4000}},
+			wantCode:  http.StatusOK,
+			wantCalls: 1,
+			wantScale: 1,
+		},
+		{
+			name: "retry after secondary limit",
+			responses: []response{
+				{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "30"}, remaining: 4000},
+				{status: http.StatusOK, remaining: 3999},
+			},
+			wantCode:  http.StatusOK,
+			wantCalls: 2,
+			wantSlept: []time.Duration{30 * time.Second},
+			wantScale: 0.5,
+		},
+		{
+			name: "secondary limit without Retry-After",
+			responses: []response{
+				{status: http.StatusForbidden, body: `{"message":"You have exceeded a secondary rate limit."}`, remaining: 4000},
+				{status: http.StatusOK, remaining: 3999},
+			},
+			wantCode:  http.StatusOK,
+			wantCalls: 2,
+			wantSlept: []time.Duration{secondaryLimitWait},
+			wantScale: 0.5,
+		},
+		{
+			name: "primary limit exhausted waits for reset",
+			responses: []response{
+				{status: http.StatusForbidden, body: `{"message":"API rate limit exceeded"}`, remaining: 0},
+				{status: http.StatusOK, remaining: 4999},
+			},
+			wantCode:  http.StatusOK,
+			wantCalls: 2,
+			wantSlept: []time.Duration{10 * time.Minute},
+			wantScale: 1,
+		},
+		{
+			name: "permission error is not retried",
+			responses: []response{
+				{status: http.StatusForbidden, body: `{"message":"Resource not accessible by integration"}`, remaining: 4000},
+			},
+			wantCode:  http.StatusForbidden,
+			wantCalls: 1,
+			wantScale: 1,
+		},
+		{
+			name: "gives up after retries",
+			responses: []response{
+				{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "1"}, remaining: 4000},
+				{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "1"}, remaining: 4000},
+				{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "1"}, remaining: 4000},
+				{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "1"}, remaining: 4000},
+			},
+			wantCode:  http.StatusTooManyRequests,
+			wantCalls: 4,
+			wantSlept: []time.Duration{time.Second, time.Second, time.Second},
+			wantScale: 0.0625,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, clock := newTestRateLimiter()
+			reset := clock.now.Add(10 * time.Minute)
+
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				resp := tt.responses[min(calls, len(tt.responses)-1)]
+				calls++
+
+				w.Header().Set("X-RateLimit-Limit", "5000")
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(resp.remaining))
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+				for k, v := range resp.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(resp.status)
+				_, _ = w.Write([]byte(resp.body))
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: limiter.Transport(nil)}
+			req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(`{"content":"x"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if len(clock.slept) != len(tt.wantSlept) {
+				t.Fatalf("slept %v, want %v", clock.slept, tt.wantSlept)
+			}
+			for i := range clock.slept {
+				if clock.slept[i] != tt.wantSlept[i] {
+					t.Errorf("slept %v, want %v", clock.slept, tt.wantSlept)
+				}
+			}
+			if limiter.scale != tt.wantScale {
+				t.Errorf("scale = %v, want %v", limiter.scale, tt.wantScale)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Concurrency(t *testing.T) {
+	limiter, _ := newTestRateLimiter()
+	if got := (*RateLimiter)(nil).Concurrency(10); got != 10 {
+		t.Errorf("nil Concurrency() = %d, want 10", got)
+	}
+	if got := limiter.Concurrency(10); got != 10 {
+		t.Errorf("Concurrency() = %d, want 10", got)
+	}
+
+	limited := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"1"}},
+	}
+	limiter.update(limited, nil)
+	if got := limiter.Concurrency(10); got != 5 {
+		t.Errorf("Concurrency() after secondary limit = %d, want 5", got)
+	}
+	limiter.update(limited, nil)
+	limiter.update(limited, nil)
+	limiter.update(limited, nil)
+	limiter.update(limited, nil)
+	if got := limiter.Concurrency(10); got != 1 {
+		t.Errorf("Concurrency() never drops below 1, got %d", got)
+	}
+
+	// Recovers as requests succeed again
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	for range 5 * recoverAfter {
+		limiter.update(ok, nil)
+	}
+	if got := limiter.Concurrency(10); got != 10 {
+		t.Errorf("Concurrency() after recovery = %d, want 10", got)
+	}
+
+	// A nearly exhausted budget allows one repository at a time
+	low := &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"X-Ratelimit-Limit":     []string{"5000"},
+		"X-Ratelimit-Remaining": []string{"400"},
+	}}
+	limiter.update(low, nil)
+	if got := limiter.Concurrency(10); got != 1 {
+		t.Errorf("Concurrency() with low budget = %d, want 1", got)
+	}
+}
@@ -0,0 +1,60 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how requests failing with a transient error are
+// retried. Rate limits are handled by the RateLimiter instead.
+type RetryPolicy struct {
+	// MaxAttempts is the number of tries including the first one
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles with
+	// every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy tries a request four times, backing off from half a
+// second up to 30 seconds
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// backoff returns the jittered delay before the given retry, counting from
+// zero. Half of the exponential delay is fixed and half random, so clients
+// failing together do not retry in lockstep.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1) //nolint:gosec // jitter needs no crypto randomness
+}
+
+// do runs op, retrying it while it fails with a transient error. The
+// returned error is classified.
+func (p RetryPolicy) do(ctx context.Context, op func() error) error {
+	for retry := 0; ; retry++ {
+		err := classify(op())
+		if err == nil || !errors.Is(err, ErrTransient) || retry+1 >= p.MaxAttempts {
+			return err
+		}
+
+		if err := sleepContext(ctx, p.backoff(retry)); err != nil {
+			return err
+		}
+	}
+}
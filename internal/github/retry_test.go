@@ -0,0 +1,152 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fastRetries retries without waiting noticeably
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+// newStatusClient creates a client against a server answering with the
+// given statuses in turn, repeating the last one, and counts the requests
+func newStatusClient(t *testing.T, statuses ...int) (*Client, *int) {
+	t.Helper()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status := statuses[min(calls, len(statuses)-1)]
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_, _ = w.Write([]byte(`{"name":"repo","default_branch":"main"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"failed"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := Endpoint{BaseURL: srv.URL}.newGitHubClient(srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClientFromGitHub(client, "acme")
+	c.SetRetryPolicy(fastRetries)
+	return c, &calls
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantKind  error
+		wantCalls int
+	}{
+		{
+			name:      "success",
+			statuses:  []int{http.StatusOK},
+			wantCalls: 1,
+		},
+		{
+			name:      "transient errors are retried",
+			statuses:  []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after max attempts",
+			statuses:  []int{http.StatusInternalServerError},
+			wantKind:  ErrTransient,
+			wantCalls: 3,
+		},
+		{
+			name:      "not found is not retried",
+			statuses:  []int{http.StatusNotFound},
+			wantKind:  ErrNotFound,
+			wantCalls: 1,
+		},
+		{
+			name:      "forbidden is not retried",
+			statuses:  []int{http.StatusForbidden},
+			wantKind:  ErrForbidden,
+			wantCalls: 1,
+		},
+		{
+			name:      "conflict is not retried",
+			statuses:  []int{http.StatusConflict},
+			wantKind:  ErrConflict,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, calls := newStatusClient(t, tt.statuses...)
+
+			_, err := client.GetRepository(context.Background(), "repo")
+			if tt.wantKind == nil && err != nil {
+				t.Fatalf("GetRepository() error = %v", err)
+			}
+			if tt.wantKind != nil && !errors.Is(err, tt.wantKind) {
+				t.Fatalf("GetRepository() error = %v, want %v", err, tt.wantKind)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("expected %d requests, got %d", tt.wantCalls, *calls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_StopsWhenCanceled(t *testing.T) {
+	client, calls := newStatusClient(t, http.StatusBadGateway)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.GetRepository(ctx, "repo")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetRepository() error = %v, want deadline exceeded", err)
+	}
+	if *calls != 1 {
+		t.Errorf("expected no retry after cancellation, got %d requests", *calls)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	for retry, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		got := policy.backoff(retry)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %s, want between %s and %s", retry, got, want/2, want)
+		}
+	}
+}
+
+func TestClassify_SHAMismatch(t *testing.T) {
+	// A create racing another create is rejected for the missing SHA
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"name":"repo","default_branch":"main"}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"message":"Invalid request.\n\n\"sha\" wasn't supplied."}`))
+	}))
+	defer srv.Close()
+
+	gh, err := Endpoint{BaseURL: srv.URL}.newGitHubClient(srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewClientFromGitHub(gh, "acme").CreateOrUpdateFile(context.Background(), "repo", ConfigPath, "msg", []byte("x"), "")
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateOrUpdateFile() error = %v, want conflict", err)
+	}
+}
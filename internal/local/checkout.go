@@ -0,0 +1,189 @@
+// Package local provides access to a repository checked out on disk.
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	githubClient "github.com/enthus-appdev/dependabot-config-manager/internal/github"
+	"gopkg.in/yaml.v3"
+)
+
+// skipDirs are directories that never contain files Dependabot manages, such
+// as VCS metadata and installed dependencies
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	".venv":        true,
+	"venv":         true,
+	".terraform":   true,
+	"vendor":       true,
+}
+
+// Checkout is a repository working tree on disk
+type Checkout struct {
+	root string
+}
+
+// New creates a checkout rooted at dir
+func New(dir string) *Checkout {
+	return &Checkout{root: dir}
+}
+
+// Root returns the working tree directory
+func (c *Checkout) Root() string {
+	return c.root
+}
+
+// GetTree lists the paths of all files in the working tree. Files ignored by
+// git are left out when the working tree is a git repository. The repository
+// name is ignored; it exists to satisfy detector.TreeSource.
+func (c *Checkout) GetTree(_ context.Context, _ string) ([]string, error) {
+	if paths, ok := c.listGitFiles(); ok {
+		return paths, nil
+	}
+
+	var paths []string
+
+	err := filepath.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != c.root && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk working tree: %w", err)
+	}
+
+	return paths, nil
+}
+
+// listGitFiles lists the tracked and untracked but not ignored files of the
+// working tree with git. It reports false if git is unavailable or the
+// working tree is not a git repository.
+func (c *Checkout) listGitFiles() ([]string, bool) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+	cmd.Dir = c.root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+
+	paths := []string{}
+	for _, path := range strings.Split(string(bytes.TrimRight(out, "\x00")), "\x00") {
+		if path == "" || inSkipDir(path) {
+			continue
+		}
+		// Deleted files are still listed until the deletion is staged, and
+		// submodules are listed as a single entry
+		info, err := os.Lstat(filepath.Join(c.root, filepath.FromSlash(path)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, true
+}
+
+// inSkipDir reports whether path is inside one of skipDirs
+func inSkipDir(path string) bool {
+	dirs := strings.Split(path, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if skipDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFileContent reads a file relative to the working tree root, returning
+// nil content if it does not exist. The repository name is ignored and no
+// SHA is returned; it exists to satisfy detector.FileSource.
+func (c *Checkout) GetFileContent(_ context.Context, _ string, path string) ([]byte, string, error) {
+	content, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return content, "", nil
+}
+
+// ReadExistingConfig reads the Dependabot configuration from the working
+// tree. It returns the parsed config, its raw content and the path it was
+// read from, or a nil config when the repository has none.
+func (c *Checkout) ReadExistingConfig() (*config.DependabotConfig, []byte, string, error) {
+	for _, path := range githubClient.ConfigPaths {
+		content, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(path)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		var cfg config.DependabotConfig
+		if err := yaml.Unmarshal(content, &cfg); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to parse existing config: %w", err)
+		}
+		return &cfg, content, path, nil
+	}
+
+	return nil, nil, githubClient.ConfigPath, nil
+}
+
+// CodeOwnersTeam returns the team owning the root of the working tree
+// according to its CODEOWNERS file, or an empty string if there is none
+func (c *Checkout) CodeOwnersTeam() (string, error) {
+	for _, path := range githubClient.CodeOwnersPaths {
+		content, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(path)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return githubClient.RootTeam(content), nil
+	}
+
+	return "", nil
+}
+
+// WriteFile writes content to a path relative to the working tree root,
+// creating parent directories as needed
+func (c *Checkout) WriteFile(path string, content []byte) error {
+	target := filepath.Join(c.root, filepath.FromSlash(path))
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.WriteFile(target, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package local
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for path, content := range files {
+		target := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckout_GetTree(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"package.json":                    "{}",
+		"web/package.json":                "{}",
+		"node_modules/react/package.json": "{}",
+		"vendor/modules.txt":              "",
+		".git/config":                     "",
+		".github/workflows/ci.yml":        "",
+	})
+
+	paths, err := New(root).GetTree(context.Background(), "repo")
+	if err != nil {
+		t.Fatalf("GetTree() error = %v", err)
+	}
+
+	sort.Strings(paths)
+	got := strings.Join(paths, ",")
+	expected := ".github/workflows/ci.yml,package.json,web/package.json"
+	if got != expected {
+		t.Errorf("GetTree() = %s, want %s", got, expected)
+	}
+}
+
+func TestCheckout_GetTree_gitignore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":         "build/\n",
+		"package.json":       "{}",
+		"build/package.json": "{}",
+		"web/package.json":   "{}",
+		"vendor/modules.txt": "",
+	})
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+
+	paths, err := New(root).GetTree(context.Background(), "repo")
+	if err != nil {
+		t.Fatalf("GetTree() error = %v", err)
+	}
+
+	sort.Strings(paths)
+	got := strings.Join(paths, ",")
+	expected := ".gitignore,package.json,web/package.json"
+	if got != expected {
+		t.Errorf("GetTree() = %s, want %s", got, expected)
+	}
+}
+
+func TestCheckout_ReadExistingConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		expectConfig bool
+		expectPath   string
+	}{
+		{
+			name:         "no config",
+			files:        map[string]string{"go.mod": "module x"},
+			expectConfig: false,
+			expectPath:   ".github/dependabot.yml",
+		},
+		{
+			name:         "yml config",
+			files:        map[string]string{".github/dependabot.yml": "version: 2\n"},
+			expectConfig: true,
+			expectPath:   ".github/dependabot.yml",
+		},
+		{
+			name:         "yaml config",
+			files:        map[string]string{".github/dependabot.yaml": "version: 2\n"},
+			expectConfig: true,
+			expectPath:   ".github/dependabot.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+
+			cfg, _, path, err := New(root).ReadExistingConfig()
+			if err != nil {
+				t.Fatalf("ReadExistingConfig() error = %v", err)
+			}
+			if (cfg != nil) != tt.expectConfig {
+				t.Errorf("ReadExistingConfig() config = %v, want present=%v", cfg, tt.expectConfig)
+			}
+			if cfg != nil && cfg.Version != 2 {
+				t.Errorf("ReadExistingConfig() version = %d, want 2", cfg.Version)
+			}
+			if path != tt.expectPath {
+				t.Errorf("ReadExistingConfig() path = %s, want %s", path, tt.expectPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package merger
+
+import (
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"gopkg.in/yaml.v3"
+)
+
+// Collapse replaces the updates of an ecosystem that differ only in their
+// directory by a single update listing the directories under directories,
+// as glob patterns where several share a parent. Updates already listing
+// directories take part too: their patterns are resolved against the
+// detected ecosystems, so the list is recomputed on every run and new
+// directories join it. Patterns matching no detected directory are kept as
+// they are; Prune removes them. Updates of root-only ecosystems and updates
+// with settings of their own are left as they are.
+func Collapse(cfg *config.DependabotConfig, ecosystems []detector.Ecosystem) {
+	type group struct {
+		first int
+		dirs  []string
+		// multi is set when an update of the group lists directories
+		multi bool
+		// unmatched are the patterns matching no detected directory
+		unmatched []string
+	}
+	groups := make(map[string]*group)
+	var order []string
+
+	for i, update := range cfg.Updates {
+		key, ok := collapseKey(update)
+		if !ok {
+			continue
+		}
+		g, seen := groups[key]
+		if !seen {
+			g = &group{first: i}
+			groups[key] = g
+			order = append(order, key)
+		}
+		if len(update.Directories) == 0 {
+			g.dirs = append(g.dirs, update.Directory)
+			continue
+		}
+		g.multi = true
+		detected := detectedDirectories(ecosystems, update.PackageEcosystem)
+		for _, pattern := range update.Directories {
+			matched := false
+			for _, dir := range detected {
+				if ok, _ := path.Match(pattern, dir); ok {
+					g.dirs = append(g.dirs, dir)
+					matched = true
+				}
+			}
+			if !matched {
+				g.unmatched = append(g.unmatched, pattern)
+			}
+		}
+	}
+
+	collapsed := make(map[int]config.DependabotUpdate)
+	skip := make(map[int]bool)
+	for _, key := range order {
+		g := groups[key]
+		if len(g.dirs) < 2 && !g.multi {
+			continue
+		}
+		slices.Sort(g.dirs)
+		patterns := append(directoryPatterns(slices.Compact(g.dirs)), g.unmatched...)
+		slices.Sort(patterns)
+		patterns = slices.Compact(patterns)
+
+		update := cfg.Updates[g.first]
+		update.Directory = ""
+		update.Directories = patterns
+		if len(patterns) == 1 && len(g.unmatched) == 0 {
+			// A single directory needs no list
+			update.Directory = patterns[0]
+			update.Directories = nil
+		}
+		collapsed[g.first] = update
+
+		for i, u := range cfg.Updates {
+			if k, ok := collapseKey(u); ok && k == key && i != g.first {
+				skip[i] = true
+			}
+		}
+	}
+	if len(collapsed) == 0 {
+		return
+	}
+
+	updates := make([]config.DependabotUpdate, 0, len(cfg.Updates)-len(skip))
+	for i, update := range cfg.Updates {
+		if skip[i] {
+			continue
+		}
+		if c, ok := collapsed[i]; ok {
+			update = c
+		}
+		updates = append(updates, update)
+	}
+	cfg.Updates = updates
+}
+
+// collapseKey returns what an update must share with others to be collapsed
+// with them: all its settings but the directory or directories. It reports
+// false for updates that cannot be collapsed.
+func collapseKey(update config.DependabotUpdate) (string, bool) {
+	if (update.Directory == "" && len(update.Directories) == 0) || isRootOnlyEcosystem(update.PackageEcosystem) {
+		return "", false
+	}
+
+	settings, err := toSettings(update)
+	if err != nil {
+		return "", false
+	}
+	delete(settings, "directory")
+	delete(settings, "directories")
+
+	// Maps are marshaled with sorted keys, so equal settings give equal keys
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// directoryPatterns lists dirs with the directories sharing a parent
+// replaced by a glob on the parent, e.g. /services/* for /services/api and
+// /services/web. Top-level directories are listed as they are, as /* would
+// match every directory of the repository.
+func directoryPatterns(dirs []string) []string {
+	byParent := make(map[string][]string)
+	for _, dir := range dirs {
+		byParent[path.Dir(dir)] = append(byParent[path.Dir(dir)], dir)
+	}
+
+	var patterns []string
+	for parent, children := range byParent {
+		if parent == "/" || len(children) < 2 {
+			patterns = append(patterns, children...)
+			continue
+		}
+		patterns = append(patterns, path.Join(parent, "*"))
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// detectedDirectories lists the directories ecosystems of type ecosystem
+// were detected in
+func detectedDirectories(ecosystems []detector.Ecosystem, ecosystem string) []string {
+	var dirs []string
+	for _, eco := range ecosystems {
+		if eco.Type == ecosystem {
+			dirs = append(dirs, eco.Directories...)
+		}
+	}
+	return dirs
+}
+
+// matchesDirectories reports whether dir is one of the directories of an
+// update or matched by one of its glob patterns
+func matchesDirectories(patterns []string, dir string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, dir); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package merger
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/google/go-github/v50/github"
+)
+
+func TestDirectoryPatterns(t *testing.T) {
+	tests := []struct {
+		name string
+		dirs []string
+		want []string
+	}{
+		{name: "siblings", dirs: []string{"/services/api", "/services/web"}, want: []string{"/services/*"}},
+		{name: "single child", dirs: []string{"/", "/services/api"}, want: []string{"/", "/services/api"}},
+		{name: "top level", dirs: []string{"/backend", "/frontend"}, want: []string{"/backend", "/frontend"}},
+		{
+			name: "mixed",
+			dirs: []string{"/", "/libs/a", "/libs/b", "/services/api", "/services/web/app", "/services/web/admin"},
+			want: []string{"/", "/libs/*", "/services/api", "/services/web/*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := directoryPatterns(tt.dirs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("directoryPatterns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	weekly := config.Schedule{Interval: "weekly"}
+	cfg := &config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{
+		{PackageEcosystem: "docker", Directory: "/", Schedule: weekly},
+		{PackageEcosystem: "npm", Directory: "/", Schedule: weekly},
+		{PackageEcosystem: "npm", Directory: "/services/api", Schedule: weekly},
+		{PackageEcosystem: "npm", Directory: "/services/web", Schedule: weekly},
+		{PackageEcosystem: "npm", Directory: "/tools", Schedule: weekly, Labels: []string{"tools"}},
+		{PackageEcosystem: "gomod", Directory: "/", Schedule: weekly},
+	}}
+
+	Collapse(cfg, nil)
+
+	want := []config.DependabotUpdate{
+		{PackageEcosystem: "docker", Directory: "/", Schedule: weekly},
+		{PackageEcosystem: "npm", Directories: []string{"/", "/services/*"}, Schedule: weekly},
+		{PackageEcosystem: "npm", Directory: "/tools", Schedule: weekly, Labels: []string{"tools"}},
+		{PackageEcosystem: "gomod", Directory: "/", Schedule: weekly},
+	}
+	if !reflect.DeepEqual(cfg.Updates, want) {
+		t.Errorf("Collapse() = %+v, want %+v", cfg.Updates, want)
+	}
+}
+
+func TestCollapse_recomputesDirectories(t *testing.T) {
+	weekly := config.Schedule{Interval: "weekly"}
+	cfg := &config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{
+		{PackageEcosystem: "npm", Directories: []string{"/", "/old/*", "/services/*"}, Schedule: weekly},
+		{PackageEcosystem: "npm", Directory: "/libs/ui", Schedule: weekly},
+	}}
+	ecosystems := []detector.Ecosystem{{
+		Name:        "npm",
+		Type:        "npm",
+		Directories: []string{"/", "/libs/ui", "/services/api"},
+	}}
+
+	Collapse(cfg, ecosystems)
+
+	// The new directory joins the list, the remaining service is listed as
+	// it is and the unmatched pattern is left to Prune
+	want := []config.DependabotUpdate{
+		{PackageEcosystem: "npm", Directories: []string{"/", "/libs/ui", "/old/*", "/services/api"}, Schedule: weekly},
+	}
+	if !reflect.DeepEqual(cfg.Updates, want) {
+		t.Errorf("Collapse() = %+v, want %+v", cfg.Updates, want)
+	}
+}
+
+func TestMerger_existingDirectories(t *testing.T) {
+	m, err := New(writeTemplates(t, map[string]string{
+		"npm/default.yml": "ecosystem: npm\nversion: 2\nupdates:\n- schedule:\n    interval: weekly\n  labels: [dependencies]\n",
+	}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	existing := &config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{{
+		PackageEcosystem: "npm",
+		Directories:      []string{"/services/*"},
+		Schedule:         config.Schedule{Interval: "daily"},
+		Labels:           []string{"services"},
+	}}}
+	ecosystems := []detector.Ecosystem{{
+		Name:        "npm",
+		Type:        "npm",
+		Directories: []string{"/services/api", "/services/web", "/tools"},
+	}}
+
+	merged := m.Merge(existing, ecosystems, &Target{Repo: &github.Repository{Name: github.String("monorepo")}})
+
+	if len(merged.Updates) != 2 {
+		t.Fatalf("expected the directories update and one for /tools, got %+v", merged.Updates)
+	}
+	services, tools := merged.Updates[0], merged.Updates[1]
+	if !reflect.DeepEqual(services.Directories, []string{"/services/*"}) || services.Directory != "" {
+		t.Errorf("directories should be kept, got %+v", services)
+	}
+	if services.Schedule.Interval != "weekly" || !reflect.DeepEqual(services.Labels, []string{"services", "dependencies"}) {
+		t.Errorf("directories update should be merged with the template, got %+v", services)
+	}
+	if tools.Directory != "/tools" {
+		t.Errorf("unmatched directory should get its own update, got %+v", tools)
+	}
+}
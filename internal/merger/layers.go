@@ -0,0 +1,321 @@
+package merger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/google/go-github/v50/github"
+	"gopkg.in/yaml.v3"
+)
+
+// Template layers, relative to the templates directory. They are resolved
+// in this order: the base, the ecosystem template, the overlays of the
+// topics of a repository in alphabetical order, and the repository override.
+var (
+	// baseName is the name of the base in reports
+	baseName = "common/base"
+	// baseFile holds the settings every ecosystem template extends
+	baseFile = filepath.FromSlash(baseName) + ".yml"
+	// topicsDir holds an overlay per topic, named <topic>.yml
+	topicsDir = "topics"
+	// reposDir holds an override per repository, named <repository>.yml
+	reposDir = "repos"
+)
+
+// layer is a set of update settings applied on top of the layers before it.
+// Lists are combined with the earlier layers, groups are added by name and
+// all other settings replace the earlier ones.
+type layer struct {
+	// Inherit set to false starts over instead of extending the layers
+	// before
+	Inherit *bool `yaml:"inherit,omitempty"`
+	// Override lists settings that replace the earlier ones instead of
+	// being combined with them
+	Override []string `yaml:"override,omitempty"`
+	// Update holds the settings for the updates of every ecosystem
+	Update map[string]interface{} `yaml:"update,omitempty"`
+	// Ecosystems holds the settings for the updates of one ecosystem
+	Ecosystems map[string]map[string]interface{} `yaml:"ecosystems,omitempty"`
+}
+
+// placementKeys are the settings that tell where an update applies. They
+// come from the detected ecosystems, not from layers.
+var placementKeys = []string{"package-ecosystem", "directory", "directories"}
+
+// loadLayer reads the layer at path, or returns nil if there is none
+func loadLayer(path string) (*layer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var l layer
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&l); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid layer %s: %w", path, err)
+	}
+	if err := l.validate(); err != nil {
+		return nil, fmt.Errorf("invalid layer %s: %w", path, err)
+	}
+	return &l, nil
+}
+
+// loadLayerDir reads the layers in dir keyed by file name without extension
+func loadLayerDir(dir string) (map[string]*layer, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	layers := make(map[string]*layer)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+
+		l, err := loadLayer(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if _, ok := layers[name]; ok {
+			return nil, fmt.Errorf("duplicate layer %s in %s", name, dir)
+		}
+		layers[name] = l
+	}
+	return layers, nil
+}
+
+// validate checks that the settings of the layer are valid update settings
+func (l *layer) validate() error {
+	for ecosystem := range l.Ecosystems {
+		if !config.IsEcosystem(ecosystem) {
+			return fmt.Errorf("unknown ecosystem %q", ecosystem)
+		}
+	}
+
+	for _, settings := range append([]map[string]interface{}{l.Update}, ecosystemSettings(l)...) {
+		for _, key := range placementKeys {
+			if _, ok := settings[key]; ok {
+				return fmt.Errorf("%s cannot be set in a layer", key)
+			}
+		}
+		if _, err := toUpdate(settings); err != nil {
+			return err
+		}
+		if err := checkVariables(settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ecosystemSettings returns the per-ecosystem settings of l
+func ecosystemSettings(l *layer) []map[string]interface{} {
+	settings := make([]map[string]interface{}, 0, len(l.Ecosystems))
+	for _, s := range l.Ecosystems {
+		settings = append(settings, s)
+	}
+	return settings
+}
+
+// apply layers the settings l holds for ecosystem over those of an update
+func (l *layer) apply(update map[string]interface{}, ecosystem string) map[string]interface{} {
+	if l.Inherit != nil && !*l.Inherit {
+		kept := make(map[string]interface{})
+		for _, key := range placementKeys {
+			if v, ok := update[key]; ok {
+				kept[key] = v
+			}
+		}
+		update = kept
+	}
+
+	override := make(map[string]bool, len(l.Override))
+	for _, key := range l.Override {
+		override[key] = true
+	}
+
+	// The settings for all ecosystems come first, so the ecosystem
+	// specific ones win
+	settings := combine(l.Update, l.Ecosystems[ecosystem], nil)
+	return combine(update, settings, override)
+}
+
+// combine layers child over parent. Settings in override replace those of
+// parent outright.
+func combine(parent, child map[string]interface{}, override map[string]bool) map[string]interface{} {
+	combined := make(map[string]interface{}, len(parent)+len(child))
+	for k, v := range parent {
+		combined[k] = v
+	}
+
+	for k, v := range child {
+		prev, ok := combined[k]
+		if !ok || override[k] {
+			combined[k] = v
+			continue
+		}
+
+		prevList, prevIsList := prev.([]interface{})
+		list, isList := v.([]interface{})
+		prevMap, prevIsMap := prev.(map[string]interface{})
+		m, isMap := v.(map[string]interface{})
+		switch {
+		case prevIsList && isList:
+			combined[k] = unionLists(prevList, list)
+		case k == "groups" && prevIsMap && isMap:
+			// Groups of the same name are replaced as a whole
+			combined[k] = combine(prevMap, m, nil)
+		default:
+			combined[k] = v
+		}
+	}
+	return combined
+}
+
+// unionLists appends the items of b missing from a
+func unionLists(a, b []interface{}) []interface{} {
+	result := append([]interface{}{}, a...)
+	for _, item := range b {
+		found := false
+		for _, existing := range result {
+			if reflect.DeepEqual(existing, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// toSettings converts an update into the generic form layers work on
+func toSettings(update config.DependabotUpdate) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// toUpdate converts layered settings back into an update
+func toUpdate(settings map[string]interface{}) (config.DependabotUpdate, error) {
+	var update config.DependabotUpdate
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return update, err
+	}
+	if err := yaml.Unmarshal(data, &update); err != nil {
+		return update, err
+	}
+	return update, nil
+}
+
+// loadLayers loads the base, topic overlays and repository overrides
+func (m *Merger) loadLayers() error {
+	var err error
+	if m.base, err = loadLayer(filepath.Join(m.templatesDir, baseFile)); err != nil {
+		return err
+	}
+	if m.topics, err = loadLayerDir(filepath.Join(m.templatesDir, topicsDir)); err != nil {
+		return err
+	}
+	if m.repos, err = loadLayerDir(filepath.Join(m.templatesDir, reposDir)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// baseUpdate returns the update the base layer defines for ecosystem
+func (m *Merger) baseUpdate(ecosystem string) map[string]interface{} {
+	update := map[string]interface{}{"package-ecosystem": ecosystem}
+	if m.base == nil {
+		return update
+	}
+	return m.base.apply(update, ecosystem)
+}
+
+// overlays returns the layers that apply to a repository on top of the
+// ecosystem templates
+func (m *Merger) overlays(repo *github.Repository) []*layer {
+	if repo == nil {
+		return nil
+	}
+
+	topics := append([]string{}, repo.Topics...)
+	sort.Strings(topics)
+
+	var layers []*layer
+	for _, topic := range topics {
+		if l, ok := m.topics[topic]; ok {
+			layers = append(layers, l)
+		}
+	}
+	if l, ok := m.repos[repo.GetName()]; ok {
+		layers = append(layers, l)
+	}
+	return layers
+}
+
+// template returns the template of an ecosystem for repo with its overlays
+// applied, and the name of the template
+func (m *Merger) template(ecosystem string, repo *github.Repository) (config.DependabotConfig, string, bool) {
+	tmpl, name, ok := m.selectTemplate(ecosystem, repo)
+	if !ok {
+		return tmpl, "", false
+	}
+
+	layers := m.overlays(repo)
+	if len(layers) == 0 {
+		return tmpl, name, true
+	}
+
+	updates := make([]config.DependabotUpdate, len(tmpl.Updates))
+	for i, update := range tmpl.Updates {
+		updates[i] = overlay(update, ecosystem, layers)
+	}
+	tmpl.Updates = updates
+	return tmpl, name, true
+}
+
+// overlay applies layers to an update. Layers are validated when loaded, so
+// the conversions do not fail in practice; the update is then left as is.
+func overlay(update config.DependabotUpdate, ecosystem string, layers []*layer) config.DependabotUpdate {
+	settings, err := toSettings(update)
+	if err != nil {
+		return update
+	}
+	for _, l := range layers {
+		settings = l.apply(settings, ecosystem)
+	}
+
+	layered, err := toUpdate(settings)
+	if err != nil {
+		return update
+	}
+	return layered
+}
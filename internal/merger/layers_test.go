@@ -0,0 +1,248 @@
+package merger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/google/go-github/v50/github"
+)
+
+// writeTemplates creates the files of a templates directory
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		name     string
+		parent   map[string]interface{}
+		child    map[string]interface{}
+		override map[string]bool
+		want     map[string]interface{}
+	}{
+		{
+			name:   "lists are combined",
+			parent: map[string]interface{}{"labels": []interface{}{"dependencies"}},
+			child:  map[string]interface{}{"labels": []interface{}{"npm", "dependencies"}},
+			want:   map[string]interface{}{"labels": []interface{}{"dependencies", "npm"}},
+		},
+		{
+			name:     "overridden lists are replaced",
+			parent:   map[string]interface{}{"labels": []interface{}{"dependencies"}},
+			child:    map[string]interface{}{"labels": []interface{}{"npm"}},
+			override: map[string]bool{"labels": true},
+			want:     map[string]interface{}{"labels": []interface{}{"npm"}},
+		},
+		{
+			name:   "mappings are replaced",
+			parent: map[string]interface{}{"schedule": map[string]interface{}{"interval": "weekly", "day": "monday"}},
+			child:  map[string]interface{}{"schedule": map[string]interface{}{"interval": "daily"}},
+			want:   map[string]interface{}{"schedule": map[string]interface{}{"interval": "daily"}},
+		},
+		{
+			name: "groups are added by name",
+			parent: map[string]interface{}{"groups": map[string]interface{}{
+				"aws":  map[string]interface{}{"patterns": []interface{}{"boto3*"}},
+				"test": map[string]interface{}{"patterns": []interface{}{"pytest*"}},
+			}},
+			child: map[string]interface{}{"groups": map[string]interface{}{
+				"aws": map[string]interface{}{"patterns": []interface{}{"aws*"}},
+			}},
+			want: map[string]interface{}{"groups": map[string]interface{}{
+				"aws":  map[string]interface{}{"patterns": []interface{}{"aws*"}},
+				"test": map[string]interface{}{"patterns": []interface{}{"pytest*"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := combine(tt.parent, tt.child, tt.override)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("combine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerger_layers(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"common/base.yml": `
+update:
+  schedule:
+    interval: daily
+  labels: [dependencies]
+  commit-message:
+    prefix: chore
+ecosystems:
+  npm:
+    open-pull-requests-limit: 5
+`,
+		"npm/default.yml": `
+ecosystem: npm
+version: 2
+updates:
+- labels: [javascript]
+`,
+		"docker/default.yml": `
+ecosystem: docker
+inherit: false
+version: 2
+updates:
+- schedule:
+    interval: monthly
+`,
+		"topics/team-data.yml": `
+update:
+  labels: [team-data]
+  reviewers: [acme/data]
+`,
+		"topics/frontend.yml": `
+ecosystems:
+  npm:
+    schedule:
+      interval: weekly
+`,
+		"repos/web-app.yml": `
+override: [labels]
+update:
+  labels: [web]
+`,
+	})
+
+	m, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ecosystems := []detector.Ecosystem{
+		{Name: "npm", Type: "npm", Directories: []string{"/"}},
+		{Name: "docker", Type: "docker", Directories: []string{"/"}},
+		{Name: "gomod", Type: "gomod", Directories: []string{"/"}},
+	}
+	updates := func(repo *github.Repository) map[string]config.DependabotUpdate {
+		byEcosystem := make(map[string]config.DependabotUpdate)
+		for _, u := range m.Merge(nil, ecosystems, &Target{Repo: repo}).Updates {
+			byEcosystem[u.PackageEcosystem] = u
+		}
+		return byEcosystem
+	}
+
+	// Without overlays: base and ecosystem templates
+	plain := updates(&github.Repository{Name: github.String("api-service")})
+	npm := plain["npm"]
+	if npm.Schedule.Interval != "daily" || !reflect.DeepEqual(npm.Labels, []string{"dependencies", "javascript"}) {
+		t.Errorf("npm should extend the base, got %+v", npm)
+	}
+	if npm.OpenPullRequestsLimit == nil || *npm.OpenPullRequestsLimit != 5 || npm.CommitMessage == nil {
+		t.Errorf("npm should inherit the base settings for npm, got %+v", npm)
+	}
+	docker := plain["docker"]
+	if docker.Schedule.Interval != "monthly" || docker.Labels != nil || docker.CommitMessage != nil {
+		t.Errorf("docker should not inherit from the base, got %+v", docker)
+	}
+	gomod := plain["gomod"]
+	if gomod.Schedule.Interval != "daily" || !reflect.DeepEqual(gomod.Labels, []string{"dependencies"}) {
+		t.Errorf("ecosystems without a template should use the base, got %+v", gomod)
+	}
+
+	// Topic overlays in alphabetical order, then the repository override
+	layered := updates(&github.Repository{
+		Name:   github.String("web-app"),
+		Topics: []string{"team-data", "frontend"},
+	})
+	npm = layered["npm"]
+	if npm.Schedule.Interval != "weekly" {
+		t.Errorf("frontend overlay should set the npm schedule, got %q", npm.Schedule.Interval)
+	}
+	if !reflect.DeepEqual(npm.Reviewers, []string{"acme/data"}) {
+		t.Errorf("team-data overlay should add reviewers, got %v", npm.Reviewers)
+	}
+	if !reflect.DeepEqual(npm.Labels, []string{"web"}) {
+		t.Errorf("repository override should replace the labels, got %v", npm.Labels)
+	}
+	if layered["gomod"].Schedule.Interval != "daily" {
+		t.Errorf("npm overlay should not apply to gomod, got %+v", layered["gomod"])
+	}
+
+	// The templates themselves are not modified by overlays
+	if again := updates(nil)["npm"]; again.Schedule.Interval != "daily" || len(again.Reviewers) != 0 {
+		t.Errorf("overlays leaked into the templates: %+v", again)
+	}
+}
+
+func TestMerger_fallbackEcosystems(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "base", files: map[string]string{"common/base.yml": "update:\n  schedule:\n    interval: daily\n"}},
+		{name: "no base", files: map[string]string{"npm/default.yml": "ecosystem: npm\nversion: 2\nupdates:\n- schedule:\n    interval: daily\n"}},
+	}
+
+	// Without a template, only ecosystems Dependabot knows get an update
+	ecosystems := []detector.Ecosystem{
+		{Name: "gomod", Type: "gomod", Directories: []string{"/"}},
+		{Name: "hex", Type: "hex", Directories: []string{"/"}},
+	}
+	existing := &config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mustNew(t, writeTemplates(t, tt.files))
+
+			created := m.Merge(nil, ecosystems, nil)
+			if len(created.Updates) != 1 || created.Updates[0].PackageEcosystem != "gomod" {
+				t.Errorf("Merge(nil) updates = %+v, want gomod only", created.Updates)
+			}
+
+			merged := m.Merge(existing, ecosystems, nil)
+			for _, update := range merged.Updates {
+				if update.PackageEcosystem == "hex" {
+					t.Errorf("Merge() added an update for unknown ecosystem hex: %+v", merged.Updates)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadLayer_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown key", content: "defaults:\n  labels: [x]\n", wantErr: "field defaults not found"},
+		{name: "placement key", content: "update:\n  directory: /\n", wantErr: "directory cannot be set"},
+		{name: "unknown ecosystem", content: "ecosystems:\n  hex:\n    labels: [x]\n", wantErr: `unknown ecosystem "hex"`},
+		{name: "invalid setting", content: "update:\n  labels: dependencies\n", wantErr: "cannot unmarshal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTemplates(t, map[string]string{"topics/team-data.yml": tt.content})
+
+			_, err := New(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,10 +2,17 @@
 package merger
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
 	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
@@ -16,6 +23,21 @@ import (
 type Merger struct {
 	templates    map[string]config.DependabotConfig
 	templatesDir string
+
+	// sources names the file of the template of each ecosystem
+	sources map[string]string
+	// variants are the templates selected by repository, by ecosystem
+	variants map[string][]variant
+
+	// policy is the merge strategy per update setting
+	policy map[string]strategy
+	// stagger spreads schedules across repositories, if configured
+	stagger *stagger
+
+	// base, topics and repos are the layers around the ecosystem templates
+	base   *layer
+	topics map[string]*layer
+	repos  map[string]*layer
 }
 
 // New creates a new config merger with templates
@@ -23,6 +45,8 @@ func New(templatesDir string) (*Merger, error) {
 	m := &Merger{
 		templates:    make(map[string]config.DependabotConfig),
 		templatesDir: templatesDir,
+		sources:      make(map[string]string),
+		variants:     make(map[string][]variant),
 	}
 
 	if err := m.loadTemplates(); err != nil {
@@ -32,47 +56,89 @@ func New(templatesDir string) (*Merger, error) {
 	return m, nil
 }
 
-// Merge combines org standard with existing config
-func (m *Merger) Merge(existing *config.DependabotConfig, ecosystems []detector.Ecosystem) *config.DependabotConfig {
+// Fingerprint identifies the loaded templates. It changes whenever a
+// template does, so results merged with other templates can be told apart.
+func (m *Merger) Fingerprint() string {
+	sum := sha256.Sum256(m.source())
+	return hex.EncodeToString(sum[:])
+}
+
+// source serializes the loaded templates and layers. Maps are marshaled
+// with sorted keys, so the output is stable.
+func (m *Merger) source() []byte {
+	data, err := yaml.Marshal(struct {
+		Templates map[string]config.DependabotConfig
+		Variants  map[string][]variant
+		Policy    map[string]strategy
+		Base      *layer
+		Topics    map[string]*layer
+		Repos     map[string]*layer
+		Stagger   *stagger
+	}{m.templates, m.variants, m.policy, m.base, m.topics, m.repos, m.stagger})
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Merge combines org standard with existing config. The topic overlays and
+// override of the target repository apply on top of the templates, and
+// template variables are expanded for it; target may be nil.
+func (m *Merger) Merge(existing *config.DependabotConfig, ecosystems []detector.Ecosystem, target *Target) *config.DependabotConfig {
 	if existing == nil {
-		return m.createFromTemplates(ecosystems)
+		return m.createFromTemplates(ecosystems, target)
 	}
 
+	// Keep the top-level settings of the existing file
 	merged := &config.DependabotConfig{
-		Version: 2,
-		Updates: []config.DependabotUpdate{},
+		Version:              2,
+		EnableBetaEcosystems: existing.EnableBetaEcosystems,
+		Registries:           copyMap(existing.Registries),
+		MultiEcosystemGroups: copyMap(existing.MultiEcosystemGroups),
+		Updates:              []config.DependabotUpdate{},
+		Extra:                existing.Extra,
 	}
 
+	// Updates listing several directories cover all directories they match
+	// and are merged once
+	mergedMulti := make(map[*config.DependabotUpdate]bool)
+
 	// Process each detected ecosystem
 	for _, eco := range ecosystems {
-		template, hasTemplate := m.templates[eco.Name]
+		template, _, hasTemplate := m.template(eco.Name, target.repo())
 		if !hasTemplate {
 			continue
 		}
+		mergeTopLevel(merged, template)
 
 		// For each directory in the ecosystem
 		for _, dir := range eco.Directories {
 			// Check if existing config has this ecosystem/directory
 			existingUpdate := findUpdate(existing.Updates, eco.Type, dir)
 
-			if existingUpdate != nil {
-				// Merge with existing - preserve directory for root-only ecosystems
+			if existingUpdate != nil && len(existingUpdate.Directories) > 0 {
+				if mergedMulti[existingUpdate] {
+					continue
+				}
+				mergedMulti[existingUpdate] = true
+				for _, tmplUpdate := range template.Updates {
+					merged.Updates = append(merged.Updates, m.mergeUpdate(*existingUpdate, m.instantiate(tmplUpdate, target, eco.Name, dir)))
+				}
+			} else if existingUpdate != nil {
+				// Merge with existing - always use "/" for root-only ecosystems
+				updateDir := dir
+				if isRootOnlyEcosystem(eco.Type) {
+					updateDir = "/"
+				}
 				for _, tmplUpdate := range template.Updates {
-					mergedUpdate := m.mergeUpdate(*existingUpdate, tmplUpdate)
-					// Always use "/" for root-only ecosystems
-					if isRootOnlyEcosystem(eco.Type) {
-						mergedUpdate.Directory = "/"
-					} else {
-						mergedUpdate.Directory = dir
-					}
+					mergedUpdate := m.mergeUpdate(*existingUpdate, m.instantiate(tmplUpdate, target, eco.Name, updateDir))
+					mergedUpdate.Directory = updateDir
 					merged.Updates = append(merged.Updates, mergedUpdate)
 				}
 			} else {
 				// Use template
 				for _, tmplUpdate := range template.Updates {
-					newUpdate := tmplUpdate
-					newUpdate.Directory = dir
-					merged.Updates = append(merged.Updates, newUpdate)
+					merged.Updates = append(merged.Updates, m.instantiate(tmplUpdate, target, eco.Name, dir))
 				}
 			}
 		}
@@ -89,14 +155,19 @@ func (m *Merger) Merge(existing *config.DependabotConfig, ecosystems []detector.
 					break
 				}
 			} else {
-				// For others, check both ecosystem and directory
+				// For others, check both ecosystem and directories
 				if mergedUpdate.PackageEcosystem == existingUpdate.PackageEcosystem &&
-					mergedUpdate.Directory == existingUpdate.Directory {
+					mergedUpdate.Directory == existingUpdate.Directory &&
+					slices.Equal(mergedUpdate.Directories, existingUpdate.Directories) {
 					found = true
 					break
 				}
 			}
 		}
+		// Updates for other target branches are separate entries
+		if found && existingUpdate.TargetBranch != "" && !hasTargetBranch(merged.Updates, existingUpdate) {
+			found = false
+		}
 		if !found {
 			// Normalize directory for root-only ecosystems
 			if isRootOnlyEcosystem(existingUpdate.PackageEcosystem) {
@@ -112,84 +183,72 @@ func (m *Merger) Merge(existing *config.DependabotConfig, ecosystems []detector.
 	return merged
 }
 
-// mergeUpdate merges an existing update with a template
+// mergeUpdate merges an existing update with a template, setting by setting
+// as the merge policy says. Where the update applies is kept from existing.
 func (m *Merger) mergeUpdate(existing, template config.DependabotUpdate) config.DependabotUpdate {
-	merged := existing
-
-	// Merge strategy:
-	// - PRESERVE: directory, target-branch, vendor (keep repository-specific)
-	// - MERGE: labels, reviewers, assignees, ignore rules
-	// - REPLACE: schedule, PR limits, versioning strategy
-
-	// Replace schedule with template
-	merged.Schedule = template.Schedule
-
-	// Replace PR limit
-	if template.OpenPullRequestsLimit > 0 {
-		merged.OpenPullRequestsLimit = template.OpenPullRequestsLimit
+	existingSettings, err := toSettings(existing)
+	if err != nil {
+		return existing
 	}
-
-	// Merge labels
-	merged.Labels = mergeStringSlices(existing.Labels, template.Labels)
-
-	// Merge reviewers
-	merged.Reviewers = mergeStringSlices(existing.Reviewers, template.Reviewers)
-
-	// Merge assignees
-	merged.Assignees = mergeStringSlices(existing.Assignees, template.Assignees)
-
-	// Replace versioning strategy
-	if template.VersioningStrategy != "" {
-		merged.VersioningStrategy = template.VersioningStrategy
+	templateSettings, err := toSettings(template)
+	if err != nil {
+		return existing
+	}
+	for _, key := range placementKeys {
+		delete(templateSettings, key)
 	}
 
-	// Deep merge groups
-	if len(template.Groups) > 0 {
-		if merged.Groups == nil {
-			merged.Groups = make(map[string]config.GroupConfig)
-		}
-		for name, group := range template.Groups {
-			merged.Groups[name] = group
+	merged := make(map[string]interface{}, len(existingSettings)+len(templateSettings))
+	for field, value := range existingSettings {
+		merged[field] = value
+	}
+	for field, value := range templateSettings {
+		if current, ok := merged[field]; ok {
+			merged[field] = mergeValue(m.strategyFor(field), current, value)
+		} else {
+			// Settings the repository does not have come from the template
+			merged[field] = value
 		}
 	}
 
-	// Use template commit message if not set
-	if merged.CommitMessage == nil && template.CommitMessage != nil {
-		merged.CommitMessage = template.CommitMessage
+	update, err := toUpdate(merged)
+	if err != nil {
+		return existing
 	}
-
-	return merged
+	return update
 }
 
 // createFromTemplates creates a new config from templates
-func (m *Merger) createFromTemplates(ecosystems []detector.Ecosystem) *config.DependabotConfig {
+func (m *Merger) createFromTemplates(ecosystems []detector.Ecosystem, target *Target) *config.DependabotConfig {
 	cfg := &config.DependabotConfig{
 		Version: 2,
 		Updates: []config.DependabotUpdate{},
 	}
 
 	for _, eco := range ecosystems {
-		template, hasTemplate := m.templates[eco.Name]
+		template, _, hasTemplate := m.template(eco.Name, target.repo())
+		if !hasTemplate && !config.IsEcosystem(eco.Type) {
+			continue
+		}
 		if !hasTemplate {
 			// Create a basic config if no template exists
+			basic := overlay(config.DependabotUpdate{
+				PackageEcosystem:      eco.Type,
+				Schedule:              config.Schedule{Interval: "weekly"},
+				OpenPullRequestsLimit: config.Int(10),
+				Labels:                []string{"dependencies"},
+			}, eco.Name, m.overlays(target.repo()))
 			for _, dir := range eco.Directories {
-				cfg.Updates = append(cfg.Updates, config.DependabotUpdate{
-					PackageEcosystem:      eco.Type,
-					Directory:             dir,
-					Schedule:              config.Schedule{Interval: "weekly"},
-					OpenPullRequestsLimit: 10,
-					Labels:                []string{"dependencies"},
-				})
+				cfg.Updates = append(cfg.Updates, m.instantiate(basic, target, eco.Name, dir))
 			}
 			continue
 		}
+		mergeTopLevel(cfg, template)
 
 		// Use template for each directory
 		for _, dir := range eco.Directories {
 			for _, tmplUpdate := range template.Updates {
-				update := tmplUpdate
-				update.Directory = dir
-				cfg.Updates = append(cfg.Updates, update)
+				cfg.Updates = append(cfg.Updates, m.instantiate(tmplUpdate, target, eco.Name, dir))
 			}
 		}
 	}
@@ -200,35 +259,189 @@ func (m *Merger) createFromTemplates(ecosystems []detector.Ecosystem) *config.De
 	return cfg
 }
 
-// loadTemplates loads configuration templates from the configs directory
+// instantiate creates the update of a template for ecosystem in dir of the
+// target repository: its variables are expanded and its schedule staggered
+func (m *Merger) instantiate(update config.DependabotUpdate, target *Target, ecosystem, dir string) config.DependabotUpdate {
+	update.Directory = dir
+	update = expandUpdate(update, target, ecosystem, dir)
+	if m.stagger != nil && target.repo() != nil {
+		update = m.stagger.apply(update, target.repo().GetName(), ecosystem)
+	}
+	return update
+}
+
+// templateFile is the file holding the default template of an ecosystem in
+// each subdirectory of the templates directory
+const templateFile = "default.yml"
+
+// loadTemplates loads the templates in every subdirectory of the templates
+// directory except those holding layers: default.yml as the template of its
+// ecosystem and any other YAML file as a variant selected by its when key.
 func (m *Merger) loadTemplates() error {
-	// Load ecosystem-specific templates
-	ecosystems := []string{"npm", "golang", "python", "docker", "maven", "gradle", "bundler", "cargo", "composer", "nuget", "github-actions"}
+	if err := m.loadLayers(); err != nil {
+		return err
+	}
+	policy, err := loadPolicy(filepath.Join(m.templatesDir, policyFile))
+	if err != nil {
+		return err
+	}
+	m.policy = policy
+	if m.stagger, err = loadStagger(filepath.Join(m.templatesDir, staggerFile)); err != nil {
+		return err
+	}
 
-	for _, eco := range ecosystems {
-		templatePath := filepath.Join(m.templatesDir, eco, "default.yml")
-		data, err := os.ReadFile(templatePath)
-		if err != nil {
-			continue // Template not found, skip
+	entries, err := os.ReadDir(m.templatesDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read templates directory: %w", err)
+	}
+
+	layerDirs := map[string]bool{filepath.Dir(baseFile): true, topicsDir: true, reposDir: true}
+	for _, entry := range entries {
+		if !entry.IsDir() || layerDirs[entry.Name()] {
+			continue
 		}
 
-		var tmpl config.DependabotConfig
-		if err := yaml.Unmarshal(data, &tmpl); err != nil {
-			return fmt.Errorf("failed to parse %s template: %w", eco, err)
+		dir := filepath.Join(m.templatesDir, entry.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", dir, err)
 		}
 
-		// Map ecosystem names
-		ecosystemName := eco
-		if eco == "golang" {
-			ecosystemName = "gomod"
+		for _, file := range files {
+			ext := filepath.Ext(file.Name())
+			if file.IsDir() || (ext != ".yml" && ext != ".yaml") {
+				continue
+			}
+			if err := m.loadTemplate(entry.Name(), file.Name()); err != nil {
+				return err
+			}
 		}
+	}
 
-		m.templates[ecosystemName] = tmpl
+	return nil
+}
+
+// loadTemplate loads the template file in dir, both relative to the
+// templates directory
+func (m *Merger) loadTemplate(dir, file string) error {
+	templatePath := filepath.Join(m.templatesDir, dir, file)
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", templatePath, err)
+	}
+
+	ecosystem, tmpl, when, err := m.parseTemplate(data)
+	if err != nil {
+		return fmt.Errorf("invalid template %s: %w", templatePath, err)
+	}
+	name := dir + "/" + strings.TrimSuffix(file, filepath.Ext(file))
+
+	if file != templateFile {
+		if when == nil {
+			return fmt.Errorf("invalid template %s: variant has no when selector", templatePath)
+		}
+		m.variants[ecosystem] = append(m.variants[ecosystem], variant{Name: name, When: *when, Template: tmpl})
+		return nil
 	}
 
+	if when != nil {
+		return fmt.Errorf("invalid template %s: %s applies to all repositories and cannot have a when selector", templatePath, templateFile)
+	}
+	if other, ok := m.sources[ecosystem]; ok {
+		return fmt.Errorf("templates %s and %s both declare ecosystem %q", path.Dir(other), dir, ecosystem)
+	}
+	m.sources[ecosystem] = name
+	m.templates[ecosystem] = tmpl
 	return nil
 }
 
+// templateKeys are the top-level keys of a template that configure the
+// template rather than Dependabot
+var templateKeys = []string{"ecosystem", "inherit", "override", "when"}
+
+// templateHeader holds the parts of a template file read as written
+type templateHeader struct {
+	Ecosystem interface{}              `yaml:"ecosystem"`
+	Inherit   *bool                    `yaml:"inherit"`
+	Override  []string                 `yaml:"override"`
+	When      *selector                `yaml:"when"`
+	Updates   []map[string]interface{} `yaml:"updates"`
+}
+
+// parseTemplate parses a template and returns the ecosystem it applies to
+// and its selector, if any. The ecosystem is declared with a top-level
+// ecosystem key, or else taken from the package-ecosystem of its updates.
+// Each update extends the base.
+func (m *Merger) parseTemplate(data []byte) (string, config.DependabotConfig, *selector, error) {
+	var tmpl config.DependabotConfig
+	if err := yaml.Unmarshal(data, &tmpl); err != nil {
+		return "", tmpl, nil, err
+	}
+	var header templateHeader
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return "", tmpl, nil, err
+	}
+
+	// These keys are not part of the Dependabot schema
+	for _, key := range templateKeys {
+		delete(tmpl.Extra, key)
+	}
+	if len(tmpl.Extra) == 0 {
+		tmpl.Extra = nil
+	}
+
+	if header.When != nil {
+		if err := header.When.validate(); err != nil {
+			return "", tmpl, nil, err
+		}
+	}
+
+	ecosystem, _ := header.Ecosystem.(string)
+	if header.Ecosystem != nil && ecosystem == "" {
+		return "", tmpl, nil, fmt.Errorf("ecosystem must be a non-empty string")
+	}
+
+	if len(tmpl.Updates) == 0 {
+		return "", tmpl, nil, fmt.Errorf("template has no updates")
+	}
+	for _, update := range tmpl.Updates {
+		if update.PackageEcosystem == "" {
+			continue
+		}
+		if ecosystem == "" {
+			ecosystem = update.PackageEcosystem
+		}
+		if update.PackageEcosystem != ecosystem {
+			return "", tmpl, nil, fmt.Errorf("update for %q in template for ecosystem %q", update.PackageEcosystem, ecosystem)
+		}
+	}
+
+	if ecosystem == "" {
+		return "", tmpl, nil, fmt.Errorf("no ecosystem declared")
+	}
+	if !config.IsEcosystem(ecosystem) {
+		return "", tmpl, nil, fmt.Errorf("unknown ecosystem %q", ecosystem)
+	}
+
+	// Layer each update as written over the base
+	for i, settings := range header.Updates {
+		if err := checkVariables(settings); err != nil {
+			return "", tmpl, nil, err
+		}
+		l := &layer{Inherit: header.Inherit, Override: header.Override, Update: settings}
+		update, err := toUpdate(l.apply(m.baseUpdate(ecosystem), ecosystem))
+		if err != nil {
+			return "", tmpl, nil, err
+		}
+		update.PackageEcosystem = ecosystem
+		tmpl.Updates[i] = update
+	}
+	return ecosystem, tmpl, header.When, nil
+}
+
 // Helper functions
 
 func findUpdate(updates []config.DependabotUpdate, ecosystem, directory string) *config.DependabotUpdate {
@@ -240,19 +453,77 @@ func findUpdate(updates []config.DependabotUpdate, ecosystem, directory string)
 		"gitsubmodule":   true,
 	}
 
+	// Prefer the update for the default branch over those with a
+	// target-branch, which Dependabot treats as separate entries
+	var found *config.DependabotUpdate
 	for i := range updates {
 		if updates[i].PackageEcosystem == ecosystem {
-			// For root-only ecosystems, match regardless of directory
-			if rootOnlyEcosystems[ecosystem] {
-				return &updates[i]
+			// For root-only ecosystems, match regardless of directory,
+			// for others, match exact directory or one of the directories
+			if !rootOnlyEcosystems[ecosystem] && updates[i].Directory != directory &&
+				!matchesDirectories(updates[i].Directories, directory) {
+				continue
 			}
-			// For others, match exact directory
-			if updates[i].Directory == directory {
+			if updates[i].TargetBranch == "" {
 				return &updates[i]
 			}
+			if found == nil {
+				found = &updates[i]
+			}
 		}
 	}
-	return nil
+	return found
+}
+
+// hasTargetBranch reports whether updates has an entry for the ecosystem,
+// directory and target branch of update
+func hasTargetBranch(updates []config.DependabotUpdate, update config.DependabotUpdate) bool {
+	for _, u := range updates {
+		if u.PackageEcosystem == update.PackageEcosystem &&
+			u.TargetBranch == update.TargetBranch &&
+			(isRootOnlyEcosystem(u.PackageEcosystem) || u.Directory == update.Directory) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeTopLevel adds the registries and multi-ecosystem groups a template
+// declares, keeping any already configured under the same name
+func mergeTopLevel(cfg *config.DependabotConfig, template config.DependabotConfig) {
+	if template.EnableBetaEcosystems {
+		cfg.EnableBetaEcosystems = true
+	}
+
+	for name, registry := range template.Registries {
+		if _, ok := cfg.Registries[name]; !ok {
+			if cfg.Registries == nil {
+				cfg.Registries = make(map[string]config.Registry)
+			}
+			cfg.Registries[name] = registry
+		}
+	}
+
+	for name, group := range template.MultiEcosystemGroups {
+		if _, ok := cfg.MultiEcosystemGroups[name]; !ok {
+			if cfg.MultiEcosystemGroups == nil {
+				cfg.MultiEcosystemGroups = make(map[string]config.MultiEcosystemGroup)
+			}
+			cfg.MultiEcosystemGroups[name] = group
+		}
+	}
+}
+
+// copyMap returns a shallow copy of m so merging does not modify the input
+func copyMap[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]V, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
 }
 
 func isRootOnlyEcosystem(ecosystem string) bool {
@@ -294,7 +565,10 @@ func sortUpdates(updates []config.DependabotUpdate) {
 			return updates[i].PackageEcosystem < updates[j].PackageEcosystem
 		}
 		// Then by directory
-		return updates[i].Directory < updates[j].Directory
+		if updates[i].Directory != updates[j].Directory {
+			return updates[i].Directory < updates[j].Directory
+		}
+		// Then by target branch, the default branch first
+		return updates[i].TargetBranch < updates[j].TargetBranch
 	})
 }
-
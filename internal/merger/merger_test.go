@@ -1,6 +1,7 @@
 package merger
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
@@ -87,11 +88,11 @@ func TestMerger_mergeUpdate(t *testing.T) {
 		Schedule: config.Schedule{
 			Interval: "daily",
 		},
-		OpenPullRequestsLimit: 5,
+		OpenPullRequestsLimit: config.Int(5),
 		Labels:                []string{"dependencies", "custom"},
 		Reviewers:             []string{"user1"},
 		TargetBranch:          "develop",
-		Vendor:                true,
+		Vendor:                config.Bool(true),
 	}
 
 	template := config.DependabotUpdate{
@@ -102,7 +103,7 @@ func TestMerger_mergeUpdate(t *testing.T) {
 			Day:      "monday",
 			Time:     "04:00",
 		},
-		OpenPullRequestsLimit: 10,
+		OpenPullRequestsLimit: config.Int(10),
 		Labels:                []string{"automated", "npm"},
 		Reviewers:             []string{"security-team"},
 		VersioningStrategy:    "increase",
@@ -120,8 +121,8 @@ func TestMerger_mergeUpdate(t *testing.T) {
 		t.Errorf("Schedule should be replaced with template, got %v", merged.Schedule.Interval)
 	}
 
-	if merged.OpenPullRequestsLimit != 10 {
-		t.Errorf("PR limit should be replaced with template, got %d", merged.OpenPullRequestsLimit)
+	if merged.OpenPullRequestsLimit == nil || *merged.OpenPullRequestsLimit != 10 {
+		t.Errorf("PR limit should be replaced with template, got %v", merged.OpenPullRequestsLimit)
 	}
 
 	if merged.Directory != "/" {
@@ -132,7 +133,7 @@ func TestMerger_mergeUpdate(t *testing.T) {
 		t.Errorf("Target branch should be preserved from existing, got %v", merged.TargetBranch)
 	}
 
-	if !merged.Vendor {
+	if merged.Vendor == nil || !*merged.Vendor {
 		t.Errorf("Vendor should be preserved from existing")
 	}
 
@@ -170,7 +171,7 @@ func TestMerger_createFromTemplates(t *testing.T) {
 					{
 						PackageEcosystem:      "npm",
 						Schedule:              config.Schedule{Interval: "weekly"},
-						OpenPullRequestsLimit: 10,
+						OpenPullRequestsLimit: config.Int(10),
 						Labels:                []string{"dependencies", "npm"},
 					},
 				},
@@ -181,7 +182,7 @@ func TestMerger_createFromTemplates(t *testing.T) {
 					{
 						PackageEcosystem:      "docker",
 						Schedule:              config.Schedule{Interval: "monthly"},
-						OpenPullRequestsLimit: 5,
+						OpenPullRequestsLimit: config.Int(5),
 						Labels:                []string{"dependencies", "docker"},
 					},
 				},
@@ -204,7 +205,7 @@ func TestMerger_createFromTemplates(t *testing.T) {
 		},
 	}
 
-	cfg := m.createFromTemplates(ecosystems)
+	cfg := m.createFromTemplates(ecosystems, nil)
 
 	if cfg.Version != 2 {
 		t.Errorf("Config version should be 2, got %d", cfg.Version)
@@ -235,3 +236,170 @@ func TestMerger_createFromTemplates(t *testing.T) {
 		t.Errorf("Should have 1 docker update, got %d", dockerCount)
 	}
 }
+
+func TestMerger_Merge_preservesSchema(t *testing.T) {
+	m := &Merger{
+		templates: map[string]config.DependabotConfig{
+			"npm": {
+				Version: 2,
+				Registries: map[string]config.Registry{
+					"npm-github":  {Type: "npm-registry", URL: "https://npm.pkg.github.com"},
+					"npm-private": {Type: "npm-registry", URL: "https://npm.example.com"},
+				},
+				Updates: []config.DependabotUpdate{
+					{
+						PackageEcosystem: "npm",
+						Schedule:         config.Schedule{Interval: "daily"},
+						Cooldown:         &config.Cooldown{DefaultDays: 3},
+					},
+				},
+			},
+		},
+	}
+
+	existing := &config.DependabotConfig{
+		Version:              2,
+		EnableBetaEcosystems: true,
+		Registries: map[string]config.Registry{
+			"npm-github": {Type: "npm-registry", URL: "https://custom.example.com"},
+		},
+		Updates: []config.DependabotUpdate{
+			{
+				PackageEcosystem: "npm",
+				Directory:        "/",
+				Schedule:         config.Schedule{Interval: "weekly"},
+				ExcludePaths:     []string{"legacy/**"},
+				Extra:            map[string]interface{}{"x-owner": "web"},
+			},
+			{
+				PackageEcosystem: "npm",
+				Directory:        "/",
+				TargetBranch:     "release",
+				Schedule:         config.Schedule{Interval: "monthly"},
+			},
+		},
+		Extra: map[string]interface{}{"x-managed": true},
+	}
+
+	ecosystems := []detector.Ecosystem{
+		{Name: "npm", Type: "npm", Directories: []string{"/"}},
+	}
+
+	merged := m.Merge(existing, ecosystems, nil)
+
+	if !merged.EnableBetaEcosystems || merged.Extra["x-managed"] != true {
+		t.Errorf("top-level settings should be preserved, got %+v", merged)
+	}
+	if got := merged.Registries["npm-github"].URL; got != "https://custom.example.com" {
+		t.Errorf("existing registry should win over the template, got %q", got)
+	}
+	if _, ok := merged.Registries["npm-private"]; !ok {
+		t.Errorf("template registry should be added")
+	}
+	if _, ok := existing.Registries["npm-private"]; ok {
+		t.Errorf("merging should not modify the existing config")
+	}
+
+	if len(merged.Updates) != 2 {
+		t.Fatalf("updates for other target branches should be kept, got %+v", merged.Updates)
+	}
+
+	main, release := merged.Updates[0], merged.Updates[1]
+	if main.TargetBranch != "" || release.TargetBranch != "release" {
+		t.Fatalf("unexpected update order: %+v", merged.Updates)
+	}
+	if main.Schedule.Interval != "daily" || release.Schedule.Interval != "monthly" {
+		t.Errorf("only the default branch update should follow the template, got %q and %q",
+			main.Schedule.Interval, release.Schedule.Interval)
+	}
+	if len(main.ExcludePaths) != 1 || main.Extra["x-owner"] != "web" {
+		t.Errorf("update settings should be preserved, got %+v", main)
+	}
+	if main.Cooldown == nil || main.Cooldown.DefaultDays != 3 {
+		t.Errorf("template cooldown should be applied when unset, got %+v", main.Cooldown)
+	}
+}
+
+func TestNew_discoversTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "declared and derived ecosystems",
+			files: map[string]string{
+				"golang/default.yml":    "ecosystem: gomod\nversion: 2\nupdates:\n- schedule:\n    interval: daily\n",
+				"terraform/default.yml": "version: 2\nupdates:\n- package-ecosystem: terraform\n  schedule:\n    interval: weekly\n",
+				"elixir/default.yml":    "ecosystem: mix\nversion: 2\nupdates:\n- schedule:\n    interval: weekly\n",
+				"common/base.yml":       "update:\n  labels: [dependencies]\n",
+				"README.md":             "not a template",
+			},
+			want: []string{"gomod", "mix", "terraform"},
+		},
+		{
+			name:    "malformed template",
+			files:   map[string]string{"npm/default.yml": "updates: [\n"},
+			wantErr: "npm",
+		},
+		{
+			// Hex is the package manager; Dependabot calls the ecosystem mix
+			name:    "unknown ecosystem",
+			files:   map[string]string{"hex/default.yml": "ecosystem: hex\nversion: 2\nupdates:\n- schedule:\n    interval: weekly\n"},
+			wantErr: `unknown ecosystem "hex"`,
+		},
+		{
+			name:    "update for another ecosystem",
+			files:   map[string]string{"pip/default.yml": "ecosystem: pip\nversion: 2\nupdates:\n- package-ecosystem: npm\n  schedule:\n    interval: weekly\n"},
+			wantErr: `"npm"`,
+		},
+		{
+			name:    "no ecosystem",
+			files:   map[string]string{"misc/default.yml": "version: 2\nupdates:\n- schedule:\n    interval: weekly\n"},
+			wantErr: "no ecosystem declared",
+		},
+		{
+			name: "duplicate ecosystem",
+			files: map[string]string{
+				"go/default.yml":     "ecosystem: gomod\nversion: 2\nupdates:\n- schedule:\n    interval: weekly\n",
+				"golang/default.yml": "ecosystem: gomod\nversion: 2\nupdates:\n- schedule:\n    interval: daily\n",
+			},
+			wantErr: `both declare ecosystem "gomod"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(writeTemplates(t, tt.files))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("New() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			if len(m.templates) != len(tt.want) {
+				t.Errorf("loaded %d templates, want %v", len(m.templates), tt.want)
+			}
+			for _, eco := range tt.want {
+				tmpl, ok := m.templates[eco]
+				if !ok {
+					t.Errorf("template for %s not loaded", eco)
+					continue
+				}
+				if tmpl.Extra != nil {
+					t.Errorf("%s: ecosystem key should not be part of the template, got %v", eco, tmpl.Extra)
+				}
+				for _, update := range tmpl.Updates {
+					if update.PackageEcosystem != eco {
+						t.Errorf("%s: update has package-ecosystem %q", eco, update.PackageEcosystem)
+					}
+				}
+			}
+		})
+	}
+}
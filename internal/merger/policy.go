@@ -0,0 +1,190 @@
+package merger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// strategy decides how a setting of an existing update is merged with the
+// template
+type strategy string
+
+const (
+	// preserve keeps the repository value and uses the template only when
+	// the repository has none
+	preserve strategy = "preserve"
+	// replace uses the template value whenever the template sets one
+	replace strategy = "replace"
+	// union combines the items of both lists, repository items first
+	union strategy = "union"
+	// deepMerge combines mappings key by key, template entries replacing
+	// those of the same name
+	deepMerge strategy = "deep-merge"
+	// enforceMinimum uses the template value unless the repository value is
+	// higher
+	enforceMinimum strategy = "enforce-minimum"
+	// enforceMaximum uses the template value unless the repository value is
+	// lower
+	enforceMaximum strategy = "enforce-maximum"
+)
+
+// policyFile holds the merge strategy per update setting, relative to the
+// templates directory
+var policyFile = filepath.Join("common", "merge-policy.yml")
+
+// defaultPolicy is the strategy per update setting unless the policy file
+// says otherwise. Settings not listed are preserved.
+var defaultPolicy = map[string]strategy{
+	"schedule":                 replace,
+	"open-pull-requests-limit": replace,
+	"versioning-strategy":      replace,
+	"labels":                   union,
+	"reviewers":                union,
+	"assignees":                union,
+	"groups":                   deepMerge,
+}
+
+// updateFields maps each update setting to the kind of its value
+var updateFields = fieldKinds(reflect.TypeOf(config.DependabotUpdate{}))
+
+// fieldKinds returns the kinds of the fields of struct type t by YAML key
+func fieldKinds(t reflect.Type) map[string]reflect.Kind {
+	kinds := make(map[string]reflect.Kind)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		typ := field.Type
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		kinds[name] = typ.Kind()
+	}
+	return kinds
+}
+
+// loadPolicy reads the policy file at path. Settings it lists override the
+// default policy.
+func loadPolicy(path string) (map[string]strategy, error) {
+	policy := make(map[string]strategy, len(defaultPolicy))
+	for field, s := range defaultPolicy {
+		policy[field] = s
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var strategies map[string]strategy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&strategies); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid merge policy %s: %w", path, err)
+	}
+
+	for field, s := range strategies {
+		if err := validateStrategy(field, s); err != nil {
+			return nil, fmt.Errorf("invalid merge policy %s: %w", path, err)
+		}
+		policy[field] = s
+	}
+	return policy, nil
+}
+
+// validateStrategy checks that s can be applied to the update setting field
+func validateStrategy(field string, s strategy) error {
+	kind, ok := updateFields[field]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", field)
+	}
+	for _, key := range placementKeys {
+		if field == key {
+			return fmt.Errorf("%s is taken from the detected ecosystems and has no merge strategy", field)
+		}
+	}
+
+	switch s {
+	case preserve, replace:
+		return nil
+	case union:
+		if kind == reflect.Slice {
+			return nil
+		}
+	case deepMerge:
+		if kind == reflect.Map || kind == reflect.Struct {
+			return nil
+		}
+	case enforceMinimum, enforceMaximum:
+		if kind == reflect.Int {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown strategy %q for %s", s, field)
+	}
+	return fmt.Errorf("strategy %s does not apply to %s", s, field)
+}
+
+// strategyFor returns the merge strategy of an update setting
+func (m *Merger) strategyFor(field string) strategy {
+	policy := m.policy
+	if policy == nil {
+		policy = defaultPolicy
+	}
+	if s, ok := policy[field]; ok {
+		return s
+	}
+	return preserve
+}
+
+// mergeValue merges the repository and template values of a setting both
+// have
+func mergeValue(s strategy, existing, template interface{}) interface{} {
+	switch s {
+	case replace:
+		return template
+	case union:
+		e, eIsList := existing.([]interface{})
+		t, tIsList := template.([]interface{})
+		if eIsList && tIsList {
+			return unionLists(e, t)
+		}
+	case deepMerge:
+		e, eIsMap := existing.(map[string]interface{})
+		t, tIsMap := template.(map[string]interface{})
+		if eIsMap && tIsMap {
+			merged := make(map[string]interface{}, len(e)+len(t))
+			for k, v := range e {
+				merged[k] = v
+			}
+			for k, v := range t {
+				merged[k] = v
+			}
+			return merged
+		}
+	case enforceMinimum, enforceMaximum:
+		e, eIsInt := existing.(int)
+		t, tIsInt := template.(int)
+		if eIsInt && tIsInt {
+			if (s == enforceMinimum) == (e > t) {
+				return e
+			}
+			return t
+		}
+	}
+	return existing
+}
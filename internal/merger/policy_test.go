@@ -0,0 +1,138 @@
+package merger
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]strategy
+		wantErr string
+	}{
+		{
+			name:    "overrides defaults",
+			content: "open-pull-requests-limit: enforce-maximum\nignore: union\n",
+			want:    map[string]strategy{"open-pull-requests-limit": enforceMaximum, "ignore": union, "labels": union, "schedule": replace},
+		},
+		{name: "unknown setting", content: "label: union\n", wantErr: `unknown setting "label"`},
+		{name: "unknown strategy", content: "labels: append\n", wantErr: `unknown strategy "append"`},
+		{name: "union of a mapping", content: "schedule: union\n", wantErr: "union does not apply to schedule"},
+		{name: "enforce on a list", content: "labels: enforce-minimum\n", wantErr: "enforce-minimum does not apply to labels"},
+		{name: "placement", content: "directory: replace\n", wantErr: "directory is taken from the detected ecosystems"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTemplates(t, map[string]string{policyFile: tt.content})
+
+			policy, err := loadPolicy(filepath.Join(dir, policyFile))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadPolicy() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadPolicy() error = %v", err)
+			}
+			for field, want := range tt.want {
+				if policy[field] != want {
+					t.Errorf("%s: strategy = %q, want %q", field, policy[field], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMerger_mergeUpdate_policy(t *testing.T) {
+	existing := config.DependabotUpdate{
+		PackageEcosystem:      "npm",
+		Directory:             "/web",
+		Schedule:              config.Schedule{Interval: "daily"},
+		OpenPullRequestsLimit: config.Int(20),
+		Labels:                []string{"web"},
+		Ignore:                []config.IgnoreConfig{{DependencyName: "react"}},
+		CommitMessage:         &config.CommitMessage{Prefix: "deps"},
+	}
+	template := config.DependabotUpdate{
+		PackageEcosystem:      "npm",
+		Directory:             "/",
+		Schedule:              config.Schedule{Interval: "weekly"},
+		OpenPullRequestsLimit: config.Int(10),
+		Labels:                []string{"dependencies"},
+		Ignore:                []config.IgnoreConfig{{DependencyName: "*", UpdateTypes: []string{"version-update:semver-major"}}},
+		CommitMessage:         &config.CommitMessage{Prefix: "chore", Include: "scope"},
+		VersioningStrategy:    "increase",
+	}
+
+	tests := []struct {
+		name   string
+		policy map[string]strategy
+		check  func(t *testing.T, merged config.DependabotUpdate)
+	}{
+		{
+			name: "default policy",
+			check: func(t *testing.T, merged config.DependabotUpdate) {
+				if *merged.OpenPullRequestsLimit != 10 || merged.Schedule.Interval != "weekly" {
+					t.Errorf("limit and schedule should be replaced, got %+v", merged)
+				}
+				if len(merged.Ignore) != 1 || merged.Ignore[0].DependencyName != "react" {
+					t.Errorf("ignore rules should be preserved, got %+v", merged.Ignore)
+				}
+				if merged.CommitMessage.Prefix != "deps" || merged.VersioningStrategy != "increase" {
+					t.Errorf("commit message should be kept and versioning strategy set, got %+v", merged)
+				}
+				if merged.Directory != "/web" {
+					t.Errorf("directory should be kept, got %q", merged.Directory)
+				}
+			},
+		},
+		{
+			name:   "enforce maximum",
+			policy: map[string]strategy{"open-pull-requests-limit": enforceMaximum},
+			check: func(t *testing.T, merged config.DependabotUpdate) {
+				if *merged.OpenPullRequestsLimit != 10 {
+					t.Errorf("limit above the maximum should be lowered, got %d", *merged.OpenPullRequestsLimit)
+				}
+			},
+		},
+		{
+			name:   "enforce minimum",
+			policy: map[string]strategy{"open-pull-requests-limit": enforceMinimum},
+			check: func(t *testing.T, merged config.DependabotUpdate) {
+				if *merged.OpenPullRequestsLimit != 20 {
+					t.Errorf("limit above the minimum should be kept, got %d", *merged.OpenPullRequestsLimit)
+				}
+			},
+		},
+		{
+			name:   "union and deep merge",
+			policy: map[string]strategy{"ignore": union, "commit-message": deepMerge, "schedule": preserve, "labels": replace},
+			check: func(t *testing.T, merged config.DependabotUpdate) {
+				if len(merged.Ignore) != 2 {
+					t.Errorf("ignore rules should be combined, got %+v", merged.Ignore)
+				}
+				if want := (&config.CommitMessage{Prefix: "chore", Include: "scope"}); !reflect.DeepEqual(merged.CommitMessage, want) {
+					t.Errorf("commit message should be merged by key, got %+v", merged.CommitMessage)
+				}
+				if merged.Schedule.Interval != "daily" || !reflect.DeepEqual(merged.Labels, []string{"dependencies"}) {
+					t.Errorf("schedule should be preserved and labels replaced, got %+v", merged)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Merger{policy: tt.policy}
+			tt.check(t, m.mergeUpdate(existing, template))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package merger
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"gopkg.in/yaml.v3"
+)
+
+// keepAnnotation marks an update in dependabot.yml that is never pruned,
+// written as a comment on the entry:
+//
+//	# dependabot-sync: keep
+//	- package-ecosystem: npm
+var keepAnnotation = regexp.MustCompile(`dependabot-sync:\s*keep\b`)
+
+// Prune removes the updates of cfg for ecosystems and directories detection
+// no longer finds indicator files for, and returns them. From updates listing
+// directories, the patterns matching no detected directory are removed and
+// returned as updates of their own; the update goes once none is left.
+// Updates for ecosystems the detector does not know and updates annotated as
+// kept in existing, the current content of the file, are left alone.
+func Prune(cfg *config.DependabotConfig, ecosystems []detector.Ecosystem, existing []byte) []config.DependabotUpdate {
+	kept := keptUpdates(existing)
+
+	var remaining, pruned []config.DependabotUpdate
+	for _, update := range cfg.Updates {
+		if kept[updateKey(update.PackageEcosystem, update.Directory, update.TargetBranch)] {
+			remaining = append(remaining, update)
+			continue
+		}
+		if len(update.Directories) > 0 {
+			stale := stalePatterns(update, ecosystems)
+			for _, pattern := range stale {
+				gone := update
+				gone.Directory = pattern
+				gone.Directories = nil
+				pruned = append(pruned, gone)
+			}
+			if len(stale) == len(update.Directories) {
+				continue
+			}
+			if len(stale) > 0 {
+				update.Directories = slices.DeleteFunc(slices.Clone(update.Directories), func(pattern string) bool {
+					return slices.Contains(stale, pattern)
+				})
+			}
+			remaining = append(remaining, update)
+			continue
+		}
+		if isStale(update, ecosystems) {
+			pruned = append(pruned, update)
+			continue
+		}
+		remaining = append(remaining, update)
+	}
+
+	if len(pruned) > 0 {
+		cfg.Updates = remaining
+	}
+	return pruned
+}
+
+// stalePatterns returns the directories of update that match no directory
+// its ecosystem is detected in
+func stalePatterns(update config.DependabotUpdate, ecosystems []detector.Ecosystem) []string {
+	if !detector.Detects(update.PackageEcosystem) || isRootOnlyEcosystem(update.PackageEcosystem) {
+		return nil
+	}
+
+	detected := detectedDirectories(ecosystems, update.PackageEcosystem)
+	var stale []string
+	for _, pattern := range update.Directories {
+		if !slices.ContainsFunc(detected, func(dir string) bool {
+			return matchesDirectories([]string{pattern}, dir)
+		}) {
+			stale = append(stale, pattern)
+		}
+	}
+	return stale
+}
+
+// isStale reports whether detection no longer finds the ecosystem of update
+// in its directory
+func isStale(update config.DependabotUpdate, ecosystems []detector.Ecosystem) bool {
+	if !detector.Detects(update.PackageEcosystem) || update.Directory == "" {
+		return false
+	}
+
+	for _, eco := range ecosystems {
+		if eco.Type != update.PackageEcosystem {
+			continue
+		}
+		if isRootOnlyEcosystem(eco.Type) {
+			return false
+		}
+		for _, dir := range eco.Directories {
+			if dir == update.Directory {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// keptUpdates returns the keys of the updates in content annotated as kept
+func keptUpdates(content []byte) map[string]bool {
+	kept := make(map[string]bool)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return kept
+	}
+	updates, ok := mappingValue(doc.Content[0], "updates")
+	if !ok || updates.Kind != yaml.SequenceNode {
+		return kept
+	}
+
+	for _, item := range updates.Content {
+		if item.Kind != yaml.MappingNode || !hasKeepAnnotation(item) {
+			continue
+		}
+		var update config.DependabotUpdate
+		if err := item.Decode(&update); err != nil {
+			continue
+		}
+		kept[updateKey(update.PackageEcosystem, update.Directory, update.TargetBranch)] = true
+	}
+	return kept
+}
+
+// hasKeepAnnotation reports whether the comments on an update entry, or on
+// its keys and values, carry the keep annotation
+func hasKeepAnnotation(item *yaml.Node) bool {
+	nodes := append([]*yaml.Node{item}, item.Content...)
+	for _, node := range nodes {
+		for _, comment := range []string{node.HeadComment, node.LineComment} {
+			if keepAnnotation.MatchString(comment) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// updateKey identifies an update by ecosystem, directory and target branch.
+// Root-only ecosystems have a single entry per target branch.
+func updateKey(ecosystem, directory, targetBranch string) string {
+	if isRootOnlyEcosystem(ecosystem) {
+		directory = "/"
+	}
+	return ecosystem + "\x00" + directory + "\x00" + targetBranch
+}
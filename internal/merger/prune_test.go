@@ -0,0 +1,81 @@
+package merger
+
+import (
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPrune(t *testing.T) {
+	existing := []byte(`version: 2
+updates:
+  - package-ecosystem: npm
+    directory: /
+  - package-ecosystem: npm
+    directory: /legacy
+  # dependabot-sync: keep
+  - package-ecosystem: npm
+    directory: /generated
+  - package-ecosystem: npm
+    directory: /vendor # dependabot-sync: keep
+  - package-ecosystem: docker
+    directory: /build
+  - package-ecosystem: github-actions
+    directory: /
+  - package-ecosystem: pip
+    directory: /scripts
+  - package-ecosystem: devcontainers
+    directory: /
+  - package-ecosystem: npm
+    directories: ["/packages/*"]
+  - package-ecosystem: npm
+    directories: ["/", "/services/*"]
+`)
+	var cfg config.DependabotConfig
+	if err := yaml.Unmarshal(existing, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	ecosystems := []detector.Ecosystem{
+		{Name: "npm", Type: "npm", Directories: []string{"/"}},
+		{Name: "docker", Type: "docker", Directories: []string{"/"}},
+	}
+	pruned := Prune(&cfg, ecosystems, existing)
+
+	var prunedNames []string
+	for _, u := range pruned {
+		prunedNames = append(prunedNames, u.PackageEcosystem+" "+u.Directory)
+	}
+	want := []string{"npm /legacy", "github-actions /", "pip /scripts", "npm /packages/*", "npm /services/*"}
+	if len(prunedNames) != len(want) {
+		t.Fatalf("Prune() = %v, want %v", prunedNames, want)
+	}
+	for i := range want {
+		if prunedNames[i] != want[i] {
+			t.Errorf("Prune() = %v, want %v", prunedNames, want)
+			break
+		}
+	}
+
+	// Kept, detected or unknown to the detector; of the directories only
+	// those still detected
+	if len(cfg.Updates) != 6 {
+		t.Fatalf("expected 6 remaining updates, got %+v", cfg.Updates)
+	}
+	if dirs := cfg.Updates[5].Directories; len(dirs) != 1 || dirs[0] != "/" {
+		t.Errorf("expected the stale pattern to be removed from directories, got %v", dirs)
+	}
+}
+
+func TestPrune_nothingStale(t *testing.T) {
+	cfg := &config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{
+		{PackageEcosystem: "gomod", Directory: "/"},
+	}}
+	ecosystems := []detector.Ecosystem{{Name: "gomod", Type: "gomod", Directories: []string{"/"}}}
+
+	if pruned := Prune(cfg, ecosystems, nil); len(pruned) != 0 || len(cfg.Updates) != 1 {
+		t.Errorf("Prune() = %v, remaining %v", pruned, cfg.Updates)
+	}
+}
@@ -0,0 +1,380 @@
+package merger
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// mergeKey is the YAML merge key used to pull in anchored mappings
+const mergeKey = "<<"
+
+// Render serializes cfg as YAML. When existing holds the current file, its
+// document tree is patched in place instead: comments, key order, quoting
+// and anchors are kept and only values that differ from cfg are touched.
+// The returned flag reports whether the content changed meaning; when it did
+// not, existing is returned unmodified.
+func Render(existing []byte, cfg *config.DependabotConfig, indent int) ([]byte, bool, error) {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		content, err := util.MarshalYAML(cfg, indent)
+		return content, true, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, false, fmt.Errorf("failed to parse existing config: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		content, err := util.MarshalYAML(cfg, indent)
+		return content, true, err
+	}
+
+	var desired yaml.Node
+	if err := desired.Encode(cfg); err != nil {
+		return nil, false, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	root := doc.Content[0]
+	if sameValue(root, &desired) {
+		return existing, false, nil
+	}
+
+	patchNode(root, &desired)
+	inlineDanglingAliases(root, collectAnchors(root))
+
+	content, err := util.MarshalYAML(&doc, detectIndent(existing, indent))
+	return content, true, err
+}
+
+// patchNode updates existing in place so it represents the same value as
+// desired, reusing as much of the existing tree as possible
+func patchNode(existing, desired *yaml.Node) {
+	if sameValue(existing, desired) {
+		return
+	}
+
+	// Never modify an alias target on behalf of one of its uses
+	if existing.Kind == yaml.AliasNode || existing.Kind != desired.Kind {
+		replaceNode(existing, desired)
+		return
+	}
+
+	switch existing.Kind {
+	case yaml.ScalarNode:
+		patchScalar(existing, desired)
+	case yaml.MappingNode:
+		patchMapping(existing, desired)
+	case yaml.SequenceNode:
+		patchSequence(existing, desired)
+	default:
+		replaceNode(existing, desired)
+	}
+}
+
+// replaceNode overwrites existing with desired, keeping its anchor and comments
+func replaceNode(existing, desired *yaml.Node) {
+	anchor := existing.Anchor
+	if existing.Kind == yaml.AliasNode {
+		anchor = ""
+	}
+	head, line, foot := existing.HeadComment, existing.LineComment, existing.FootComment
+
+	*existing = *desired
+	existing.Anchor = anchor
+	existing.HeadComment, existing.LineComment, existing.FootComment = head, line, foot
+}
+
+// patchScalar changes a scalar value, keeping its quoting unless the new
+// value needs quotes to keep its type
+func patchScalar(existing, desired *yaml.Node) {
+	existing.Tag = desired.Tag
+	existing.Value = desired.Value
+
+	quoted := yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+	if desired.Style&quoted != 0 && existing.Style&quoted == 0 {
+		existing.Style = desired.Style
+	}
+}
+
+// patchMapping updates, adds and removes keys while keeping the order of
+// the keys that remain
+func patchMapping(existing, desired *yaml.Node) {
+	// Keys provided through merge keys can only be overridden, not removed.
+	// Inline the merged mappings when that is required.
+	inherited := mergedPairs(existing)
+	for key := range inherited {
+		if _, ok := explicitValue(desired, key); !ok {
+			expandMergeKeys(existing)
+			inherited = nil
+			break
+		}
+	}
+
+	// Drop explicit keys that are no longer wanted
+	var content []*yaml.Node
+	for i := 0; i+1 < len(existing.Content); i += 2 {
+		key := existing.Content[i].Value
+		if key == mergeKey && inherited != nil {
+			content = append(content, existing.Content[i], existing.Content[i+1])
+			continue
+		}
+		if _, ok := explicitValue(desired, key); ok {
+			content = append(content, existing.Content[i], existing.Content[i+1])
+		}
+	}
+	existing.Content = content
+
+	// Update existing keys and append new ones in the desired order
+	for i := 0; i+1 < len(desired.Content); i += 2 {
+		key, value := desired.Content[i], desired.Content[i+1]
+
+		if current, ok := explicitValue(existing, key.Value); ok {
+			patchNode(current, value)
+			continue
+		}
+		if current, ok := inherited[key.Value]; ok && sameValue(current, value) {
+			continue
+		}
+		existing.Content = append(existing.Content, key, value)
+	}
+}
+
+// patchSequence keeps existing items that still match a desired item, in
+// their current order, drops the rest and appends new items
+func patchSequence(existing, desired *yaml.Node) {
+	used := make([]bool, len(existing.Content))
+	matches := make(map[int]int) // existing index -> desired index
+	var added []*yaml.Node
+
+	for di, item := range desired.Content {
+		ei := findMatch(existing.Content, used, item)
+		if ei < 0 {
+			added = append(added, item)
+			continue
+		}
+		used[ei] = true
+		matches[ei] = di
+	}
+
+	var content []*yaml.Node
+	for ei, item := range existing.Content {
+		di, ok := matches[ei]
+		if !ok {
+			continue
+		}
+		patchNode(item, desired.Content[di])
+		content = append(content, item)
+	}
+	existing.Content = append(content, added...)
+}
+
+// findMatch finds the unused existing item that corresponds to a desired
+// item: an identical item first, then a mapping with the same identity
+func findMatch(items []*yaml.Node, used []bool, desired *yaml.Node) int {
+	for i, item := range items {
+		if !used[i] && sameValue(item, desired) {
+			return i
+		}
+	}
+
+	id := identity(desired)
+	if id == "" {
+		return -1
+	}
+	for i, item := range items {
+		if !used[i] && identity(item) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// identity returns the fields that identify an entry in a Dependabot list:
+// the ecosystem, directory and target branch of an update, or the dependency
+// of a rule
+func identity(node *yaml.Node) string {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return ""
+	}
+
+	var parts []string
+	for _, key := range []string{"package-ecosystem", "directory", "target-branch", "dependency-name"} {
+		if value, ok := mappingValue(node, key); ok && value.Kind == yaml.ScalarNode {
+			parts = append(parts, key+"="+value.Value)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// sameValue reports whether two nodes decode to the same data
+func sameValue(a, b *yaml.Node) bool {
+	var av, bv interface{}
+	if err := a.Decode(&av); err != nil {
+		return false
+	}
+	if err := b.Decode(&bv); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// mappingValue returns the value of key in a mapping, including keys
+// provided through merge keys
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, bool) {
+	if value, ok := explicitValue(node, key); ok {
+		return value, true
+	}
+	value, ok := mergedPairs(node)[key]
+	return value, ok
+}
+
+// explicitValue returns the value of a key written directly in a mapping
+func explicitValue(node *yaml.Node, key string) (*yaml.Node, bool) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && key != mergeKey {
+			return node.Content[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// mergedPairs collects the key/value pairs a mapping inherits through merge
+// keys. Earlier sources take precedence, as in YAML.
+func mergedPairs(node *yaml.Node) map[string]*yaml.Node {
+	pairs := make(map[string]*yaml.Node)
+	for _, key := range mergedKeys(node) {
+		pairs[key.key] = key.value
+	}
+	return pairs
+}
+
+// inheritedKey is a key/value pair provided through a merge key
+type inheritedKey struct {
+	key   string
+	value *yaml.Node
+}
+
+// mergedKeys lists the keys a mapping inherits through merge keys in
+// document order, without duplicates
+func mergedKeys(node *yaml.Node) []inheritedKey {
+	var keys []inheritedKey
+	seen := make(map[string]bool)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != mergeKey {
+			continue
+		}
+
+		sources := []*yaml.Node{node.Content[i+1]}
+		if node.Content[i+1].Kind == yaml.SequenceNode {
+			sources = node.Content[i+1].Content
+		}
+
+		for _, source := range sources {
+			if source.Kind == yaml.AliasNode {
+				source = source.Alias
+			}
+			if source == nil || source.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(source.Content); j += 2 {
+				key := source.Content[j].Value
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, inheritedKey{key: key, value: source.Content[j+1]})
+				}
+			}
+		}
+	}
+
+	return keys
+}
+
+// expandMergeKeys replaces merge keys with explicit copies of the keys they
+// provide, so individual keys can be removed
+func expandMergeKeys(node *yaml.Node) {
+	inherited := mergedKeys(node)
+
+	var content []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != mergeKey {
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+	}
+	node.Content = content
+
+	for _, key := range inherited {
+		if _, ok := explicitValue(node, key.key); ok {
+			continue
+		}
+		copied := *key.value
+		copied.Anchor = ""
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.key},
+			&copied,
+		)
+	}
+}
+
+// collectAnchors finds the anchored nodes still present in a tree
+func collectAnchors(node *yaml.Node) map[*yaml.Node]bool {
+	anchors := make(map[*yaml.Node]bool)
+
+	var walk func(*yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Anchor != "" {
+			anchors[n] = true
+		}
+		if n.Kind == yaml.AliasNode {
+			return
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	return anchors
+}
+
+// inlineDanglingAliases replaces aliases whose anchor was removed from the
+// document with a copy of the value they referred to
+func inlineDanglingAliases(node *yaml.Node, anchors map[*yaml.Node]bool) {
+	for _, child := range node.Content {
+		if child.Kind == yaml.AliasNode && child.Alias != nil && !anchors[child.Alias] {
+			*child = *child.Alias
+			child.Anchor = ""
+		}
+		if child.Kind != yaml.AliasNode {
+			inlineDanglingAliases(child, anchors)
+		}
+	}
+}
+
+// detectIndent returns the indentation width used by a YAML document, or
+// fallback when it has no indented lines
+func detectIndent(content []byte, fallback int) int {
+	width := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if indent := len(line) - len(trimmed); indent > 0 && (width == 0 || indent < width) {
+			width = indent
+		}
+	}
+
+	if width < 2 {
+		return fallback
+	}
+	return width
+}
@@ -0,0 +1,276 @@
+package merger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name          string
+		existing      string
+		cfg           *config.DependabotConfig
+		expectChanged bool
+		expected      string
+	}{
+		{
+			name:     "no existing file",
+			existing: "",
+			cfg: &config.DependabotConfig{
+				Version: 2,
+				Updates: []config.DependabotUpdate{
+					{PackageEcosystem: "npm", Directory: "/", Schedule: config.Schedule{Interval: "daily", Time: "04:00"}},
+				},
+			},
+			expectChanged: true,
+			expected: `version: 2
+updates:
+  - package-ecosystem: npm
+    directory: /
+    schedule:
+      interval: daily
+      time: "04:00"
+`,
+		},
+		{
+			name: "unchanged content is returned as is",
+			existing: `version: 2
+updates:
+- package-ecosystem: "npm"   # frontend
+  directory: "/"
+  schedule: {interval: "daily"}
+`,
+			cfg: &config.DependabotConfig{
+				Version: 2,
+				Updates: []config.DependabotUpdate{
+					{PackageEcosystem: "npm", Directory: "/", Schedule: config.Schedule{Interval: "daily"}},
+				},
+			},
+			expectChanged: false,
+			expected: `version: 2
+updates:
+- package-ecosystem: "npm"   # frontend
+  directory: "/"
+  schedule: {interval: "daily"}
+`,
+		},
+		{
+			name: "comments, order and quoting survive a change",
+			existing: `# Managed by the platform team
+version: 2
+updates:
+  # Frontend dependencies
+  - directory: "/web"
+    package-ecosystem: "npm"
+    schedule:
+      interval: "weekly" # too slow
+    labels:
+      - "frontend"
+`,
+			cfg: &config.DependabotConfig{
+				Version: 2,
+				Updates: []config.DependabotUpdate{
+					{
+						PackageEcosystem: "npm",
+						Directory:        "/web",
+						Schedule:         config.Schedule{Interval: "daily", Time: "04:00"},
+						Labels:           []string{"frontend", "dependencies"},
+					},
+				},
+			},
+			expectChanged: true,
+			expected: `# Managed by the platform team
+version: 2
+updates:
+  # Frontend dependencies
+  - directory: "/web"
+    package-ecosystem: "npm"
+    schedule:
+      interval: "daily" # too slow
+      time: "04:00"
+    labels:
+      - "frontend"
+      - dependencies
+`,
+		},
+		{
+			name: "updates keep their order and comments when entries change",
+			existing: `version: 2
+updates:
+  # Go services
+  - package-ecosystem: gomod
+    directory: /
+    schedule:
+      interval: weekly
+  # Removed folder
+  - package-ecosystem: npm
+    directory: /old
+    schedule:
+      interval: weekly
+`,
+			cfg: &config.DependabotConfig{
+				Version: 2,
+				Updates: []config.DependabotUpdate{
+					{PackageEcosystem: "docker", Directory: "/", Schedule: config.Schedule{Interval: "daily"}},
+					{PackageEcosystem: "gomod", Directory: "/", Schedule: config.Schedule{Interval: "weekly"}},
+				},
+			},
+			expectChanged: true,
+			expected: `version: 2
+updates:
+  # Go services
+  - package-ecosystem: gomod
+    directory: /
+    schedule:
+      interval: weekly
+  - package-ecosystem: docker
+    directory: /
+    schedule:
+      interval: daily
+`,
+		},
+		{
+			name: "anchors and aliases are kept",
+			existing: `version: 2
+updates:
+  - package-ecosystem: npm
+    directory: /
+    schedule: &schedule
+      interval: weekly
+  - package-ecosystem: docker
+    directory: /
+    schedule: *schedule
+`,
+			cfg: &config.DependabotConfig{
+				Version: 2,
+				Updates: []config.DependabotUpdate{
+					{PackageEcosystem: "npm", Directory: "/", Schedule: config.Schedule{Interval: "daily"}},
+					{PackageEcosystem: "docker", Directory: "/", Schedule: config.Schedule{Interval: "daily"}},
+				},
+			},
+			expectChanged: true,
+			expected: `version: 2
+updates:
+  - package-ecosystem: npm
+    directory: /
+    schedule: &schedule
+      interval: daily
+  - package-ecosystem: docker
+    directory: /
+    schedule: *schedule
+`,
+		},
+		{
+			name: "alias is inlined when its anchor is removed",
+			existing: `version: 2
+updates:
+  - package-ecosystem: npm
+    directory: /old
+    schedule: &schedule
+      interval: weekly
+  - package-ecosystem: docker
+    directory: /
+    schedule: *schedule
+    labels: [docker]
+`,
+			cfg: &config.DependabotConfig{
+				Version: 2,
+				Updates: []config.DependabotUpdate{
+					{PackageEcosystem: "docker", Directory: "/", Schedule: config.Schedule{Interval: "weekly"}},
+				},
+			},
+			expectChanged: true,
+			expected: `version: 2
+updates:
+  - package-ecosystem: docker
+    directory: /
+    schedule:
+      interval: weekly
+`,
+		},
+		{
+			name: "merge keys are overridden rather than expanded",
+			existing: `version: 2
+updates:
+  - &defaults
+    package-ecosystem: npm
+    directory: /
+    schedule:
+      interval: weekly
+  - <<: *defaults
+    directory: /web
+`,
+			cfg: &config.DependabotConfig{
+				Version: 2,
+				Updates: []config.DependabotUpdate{
+					{PackageEcosystem: "npm", Directory: "/", Schedule: config.Schedule{Interval: "weekly"}},
+					{PackageEcosystem: "npm", Directory: "/web", Schedule: config.Schedule{Interval: "daily"}},
+				},
+			},
+			expectChanged: true,
+			expected: `version: 2
+updates:
+  - &defaults
+    package-ecosystem: npm
+    directory: /
+    schedule:
+      interval: weekly
+  - !!merge <<: *defaults
+    directory: /web
+    schedule:
+      interval: daily
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := Render([]byte(tt.existing), tt.cfg, 2)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if changed != tt.expectChanged {
+				t.Errorf("Render() changed = %v, want %v", changed, tt.expectChanged)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Render() =\n%s\nwant\n%s", got, tt.expected)
+			}
+
+			// The rendered document must always decode to the desired config
+			var decoded config.DependabotConfig
+			if err := yaml.Unmarshal(got, &decoded); err != nil {
+				t.Fatalf("rendered YAML is invalid: %v", err)
+			}
+			if len(decoded.Updates) != len(tt.cfg.Updates) {
+				t.Errorf("rendered config has %d updates, want %d", len(decoded.Updates), len(tt.cfg.Updates))
+			}
+		})
+	}
+}
+
+func TestRender_preservesIndentation(t *testing.T) {
+	existing := `version: 2
+updates:
+    - package-ecosystem: npm
+      directory: /
+      schedule:
+          interval: weekly
+`
+	cfg := &config.DependabotConfig{
+		Version: 2,
+		Updates: []config.DependabotUpdate{
+			{PackageEcosystem: "npm", Directory: "/", Schedule: config.Schedule{Interval: "daily"}},
+		},
+	}
+
+	got, _, err := Render([]byte(existing), cfg, 2)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.Contains(string(got), "\nupdates:\n    - package-ecosystem: npm\n") {
+		t.Errorf("Render() should keep 4-space indentation, got:\n%s", got)
+	}
+}
@@ -0,0 +1,137 @@
+package merger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// staggerFile configures schedule staggering, relative to the templates
+// directory. Without it schedules are used as the templates set them.
+var staggerFile = filepath.Join("common", "stagger.yml")
+
+// minutesPerDay is the number of schedule times in a day
+const minutesPerDay = 24 * 60
+
+// weekdays are the days a weekly schedule can run on
+var weekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// stagger spreads the schedules of repositories across a window, so their
+// updates do not all start at the same minute
+type stagger struct {
+	// Start and End bound the window as HH:MM. A window ending before it
+	// starts spans midnight.
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// Days are the days weekly schedules are spread across, all days of
+	// the week by default
+	Days []string `yaml:"days,omitempty"`
+
+	start, length int
+}
+
+// loadStagger reads the stagger configuration at path, or returns nil if
+// there is none
+func loadStagger(path string) (*stagger, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var s stagger
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid stagger configuration %s: %w", path, err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid stagger configuration %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// validate checks the window and days and computes the window in minutes
+func (s *stagger) validate() error {
+	start, err := parseMinutes(s.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseMinutes(s.End)
+	if err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("window from %s to %s is empty", s.Start, s.End)
+	}
+	s.start = start
+	s.length = (end - start + minutesPerDay) % minutesPerDay
+
+	if len(s.Days) == 0 {
+		// A copy, Days is lowercased in place below
+		s.Days = slices.Clone(weekdays)
+	}
+	for i, day := range s.Days {
+		s.Days[i] = strings.ToLower(day)
+		if !isWeekday(s.Days[i]) {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	return nil
+}
+
+// parseMinutes parses a time of day as HH:MM into minutes after midnight
+func parseMinutes(hhmm string) (int, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time as HH:MM", hhmm)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// isWeekday reports whether day names a day of the week
+func isWeekday(day string) bool {
+	for _, d := range weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the time of the schedule of update to a minute within the
+// window, and the day of a weekly schedule to one of the days. Both derive
+// from a hash of the repository and ecosystem, so they stay the same from
+// run to run. Schedules without a time of day, such as cron jobs, are left
+// as is.
+func (s *stagger) apply(update config.DependabotUpdate, repo, ecosystem string) config.DependabotUpdate {
+	switch update.Schedule.Interval {
+	case "daily", "weekly", "monthly":
+	default:
+		return update
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(repo + "/" + ecosystem))
+	sum := h.Sum64()
+
+	minute := (s.start + int(sum%uint64(s.length))) % minutesPerDay
+	update.Schedule.Time = fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+	if update.Schedule.Interval == "weekly" {
+		update.Schedule.Day = s.Days[(sum/uint64(s.length))%uint64(len(s.Days))]
+	}
+	return update
+}
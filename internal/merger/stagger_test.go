@@ -0,0 +1,159 @@
+package merger
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/google/go-github/v50/github"
+)
+
+func TestStagger_apply(t *testing.T) {
+	tests := []struct {
+		name       string
+		stagger    stagger
+		interval   string
+		start, end string
+		days       []string
+	}{
+		{name: "daily", stagger: stagger{Start: "02:00", End: "06:00"}, interval: "daily", start: "02:00", end: "05:59"},
+		{name: "across midnight", stagger: stagger{Start: "22:00", End: "02:00"}, interval: "daily", start: "22:00", end: "01:59"},
+		{name: "weekly", stagger: stagger{Start: "01:00", End: "03:00", Days: []string{"Tuesday", "thursday"}}, interval: "weekly", start: "01:00", end: "02:59", days: []string{"tuesday", "thursday"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.stagger.validate(); err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+
+			times := make(map[string]bool)
+			for i := 0; i < 50; i++ {
+				update := config.DependabotUpdate{Schedule: config.Schedule{Interval: tt.interval, Time: "04:00"}}
+				repo := fmt.Sprintf("repo-%d", i)
+				got := tt.stagger.apply(update, repo, "npm")
+
+				if again := tt.stagger.apply(update, repo, "npm"); !reflect.DeepEqual(again.Schedule, got.Schedule) {
+					t.Fatalf("apply() is not stable: %+v then %+v", got.Schedule, again.Schedule)
+				}
+				if !inWindow(got.Schedule.Time, tt.start, tt.end) {
+					t.Errorf("time %s outside %s-%s", got.Schedule.Time, tt.start, tt.end)
+				}
+				if tt.days == nil && got.Schedule.Day != "" {
+					t.Errorf("unexpected day %q", got.Schedule.Day)
+				}
+				if tt.days != nil && got.Schedule.Day != tt.days[0] && got.Schedule.Day != tt.days[1] {
+					t.Errorf("day %q not in %v", got.Schedule.Day, tt.days)
+				}
+				times[got.Schedule.Time] = true
+			}
+			if len(times) < 10 {
+				t.Errorf("expected times to be spread, got %d distinct", len(times))
+			}
+		})
+	}
+}
+
+// inWindow reports whether hhmm is within start and end inclusive, which
+// may span midnight
+func inWindow(hhmm, start, end string) bool {
+	if start <= end {
+		return hhmm >= start && hhmm <= end
+	}
+	return hhmm >= start || hhmm <= end
+}
+
+func TestStagger_applyKeepsCron(t *testing.T) {
+	s := stagger{Start: "02:00", End: "06:00"}
+	if err := s.validate(); err != nil {
+		t.Fatal(err)
+	}
+	update := config.DependabotUpdate{Schedule: config.Schedule{Interval: "cron", Cronjob: "0 4 * * *"}}
+	if got := s.apply(update, "web-app", "npm"); !reflect.DeepEqual(got.Schedule, update.Schedule) {
+		t.Errorf("cron schedule changed: %+v", got.Schedule)
+	}
+}
+
+func TestStagger_validateDefaultDays(t *testing.T) {
+	s := &stagger{Start: "01:00", End: "05:00"}
+	if err := s.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	s.Days[0] = "caturday"
+	if weekdays[0] != "monday" {
+		t.Errorf("default days should not share the weekdays, got %v", weekdays)
+	}
+}
+
+func TestLoadStagger_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "invalid time", content: "start: \"4am\"\nend: \"06:00\"\n", wantErr: "invalid start"},
+		{name: "empty window", content: "start: \"04:00\"\nend: \"04:00\"\n", wantErr: "is empty"},
+		{name: "unknown day", content: "start: \"02:00\"\nend: \"06:00\"\ndays: [someday]\n", wantErr: `unknown day "someday"`},
+		{name: "unknown key", content: "start: \"02:00\"\nend: \"06:00\"\njitter: 5\n", wantErr: "field jitter not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(writeTemplates(t, map[string]string{"common/stagger.yml": tt.content}))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMerger_stagger(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"common/stagger.yml": "start: \"01:00\"\nend: \"05:00\"\ndays: [monday, wednesday]\n",
+		"npm/default.yml":    "ecosystem: npm\nversion: 2\nupdates:\n- schedule:\n    interval: weekly\n    time: \"04:00\"\n",
+	})
+	m, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ecosystems := []detector.Ecosystem{{Name: "npm", Type: "npm", Directories: []string{"/"}}}
+	target := &Target{Repo: &github.Repository{Name: github.String("web-app")}}
+
+	created := m.Merge(nil, ecosystems, target).Updates[0].Schedule
+	if !inWindow(created.Time, "01:00", "04:59") || (created.Day != "monday" && created.Day != "wednesday") {
+		t.Errorf("schedule not staggered: %+v", created)
+	}
+
+	// The template schedule replaces the existing one by default, so merging
+	// assigns the same slot
+	existing := &config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{{
+		PackageEcosystem: "npm",
+		Directory:        "/",
+		Schedule:         config.Schedule{Interval: "weekly", Time: "04:00"},
+	}}}
+	if merged := m.Merge(existing, ecosystems, target).Updates[0].Schedule; !reflect.DeepEqual(merged, created) {
+		t.Errorf("merged schedule %+v, want %+v", merged, created)
+	}
+
+	if m.Fingerprint() == mustNew(t, writeTemplates(t, map[string]string{
+		"npm/default.yml": "ecosystem: npm\nversion: 2\nupdates:\n- schedule:\n    interval: weekly\n    time: \"04:00\"\n",
+	})).Fingerprint() {
+		t.Error("Fingerprint() should change with the stagger configuration")
+	}
+}
+
+// mustNew creates a merger for the templates in dir
+func mustNew(t *testing.T, dir string) *Merger {
+	t.Helper()
+
+	m, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return m
+}
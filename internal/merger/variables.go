@@ -0,0 +1,139 @@
+package merger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/google/go-github/v50/github"
+)
+
+// Target is the repository a configuration is merged for
+type Target struct {
+	Repo *github.Repository
+	// Team owns the repository root in CODEOWNERS, e.g. acme/platform
+	Team string
+}
+
+// repo returns the repository of t, which may be nil
+func (t *Target) repo() *github.Repository {
+	if t == nil {
+		return nil
+	}
+	return t.Repo
+}
+
+// variables are what string settings in templates and layers can reference
+// with Go template syntax, e.g. "{{ .Repo.Name }}"
+type variables struct {
+	Repo struct {
+		Name          string
+		DefaultBranch string
+		Visibility    string
+		Language      string
+	}
+	// Team owns the repository root in CODEOWNERS
+	Team string
+	// Ecosystem and Directory are those of the update
+	Ecosystem string
+	Directory string
+}
+
+// newVariables collects the variables of the update for ecosystem in dir
+func newVariables(target *Target, ecosystem, dir string) *variables {
+	vars := &variables{Ecosystem: ecosystem, Directory: dir}
+	if repo := target.repo(); repo != nil {
+		vars.Repo.Name = repo.GetName()
+		vars.Repo.DefaultBranch = repo.GetDefaultBranch()
+		vars.Repo.Visibility = visibility(repo)
+		vars.Repo.Language = repo.GetLanguage()
+	}
+	if target != nil {
+		vars.Team = target.Team
+	}
+	return vars
+}
+
+// expand executes the templates in the strings of settings. List items that
+// expand to nothing are dropped, so a reviewer of "{{ .Team }}" disappears
+// for repositories without a team.
+func expand(settings interface{}, vars *variables) (interface{}, error) {
+	switch v := settings.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := template.New("").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, vars); err != nil {
+			return nil, err
+		}
+		return sb.String(), nil
+	case []interface{}:
+		expanded := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			value, err := expand(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := value.(string); ok && s == "" {
+				continue
+			}
+			expanded = append(expanded, value)
+		}
+		return expanded, nil
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			value, err := expand(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = value
+		}
+		return expanded, nil
+	}
+	return settings, nil
+}
+
+// checkVariables checks that the templates in settings parse and reference
+// known variables only
+func checkVariables(settings map[string]interface{}) error {
+	if _, err := expand(settings, &variables{}); err != nil {
+		return fmt.Errorf("invalid template variable: %w", err)
+	}
+	return nil
+}
+
+// expandUpdate expands the variables in the settings of an update for
+// ecosystem in dir. Templates are checked when loaded, so expanding does not
+// fail in practice; the update is then left unexpanded.
+func expandUpdate(update config.DependabotUpdate, target *Target, ecosystem, dir string) config.DependabotUpdate {
+	settings, err := toSettings(update)
+	if err != nil {
+		return update
+	}
+	expanded, err := expand(settings, newVariables(target, ecosystem, dir))
+	if err != nil {
+		return update
+	}
+	instance, err := toUpdate(expanded.(map[string]interface{}))
+	if err != nil {
+		return update
+	}
+	return instance
+}
+
+// teamVariable matches a template action referencing the team
+var teamVariable = regexp.MustCompile(`\{\{[^}]*\.Team\b`)
+
+// UsesTeam reports whether any template or layer references the CODEOWNERS
+// team, which is only looked up then
+func (m *Merger) UsesTeam() bool {
+	return teamVariable.Match(m.source())
+}
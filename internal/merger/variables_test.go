@@ -0,0 +1,130 @@
+package merger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/google/go-github/v50/github"
+)
+
+func TestMerger_variables(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"npm/default.yml": `
+ecosystem: npm
+version: 2
+updates:
+- schedule:
+    interval: weekly
+  labels: [dependencies, "{{ .Repo.Name }}"]
+  reviewers: ["{{ .Team }}"]
+  target-branch: "{{ .Repo.DefaultBranch }}"
+  commit-message:
+    prefix: "deps({{ .Directory }})"
+`,
+		"topics/release.yml": "update:\n  labels: [\"{{ .Repo.DefaultBranch }}-{{ .Ecosystem }}\"]\n",
+	})
+
+	m, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !m.UsesTeam() {
+		t.Error("UsesTeam() = false for a template referencing .Team")
+	}
+
+	ecosystems := []detector.Ecosystem{{Name: "npm", Type: "npm", Directories: []string{"/", "/web"}}}
+	target := &Target{
+		Repo: &github.Repository{
+			Name:          github.String("web-app"),
+			DefaultBranch: github.String("develop"),
+			Topics:        []string{"release"},
+		},
+		Team: "acme/frontend",
+	}
+
+	created := m.Merge(nil, ecosystems, target)
+	if len(created.Updates) != 2 {
+		t.Fatalf("expected an update per directory, got %d", len(created.Updates))
+	}
+	web := created.Updates[1]
+	if !reflect.DeepEqual(web.Labels, []string{"dependencies", "web-app", "develop-npm"}) {
+		t.Errorf("unexpected labels: %v", web.Labels)
+	}
+	if !reflect.DeepEqual(web.Reviewers, []string{"acme/frontend"}) {
+		t.Errorf("unexpected reviewers: %v", web.Reviewers)
+	}
+	if web.TargetBranch != "develop" {
+		t.Errorf("unexpected target-branch: %q", web.TargetBranch)
+	}
+	if web.CommitMessage == nil || web.CommitMessage.Prefix != "deps(/web)" {
+		t.Errorf("unexpected commit-message: %+v", web.CommitMessage)
+	}
+
+	// Merging into an existing update expands the variables the same way
+	existing := &config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{{
+		PackageEcosystem: "npm",
+		Directory:        "/",
+		Schedule:         config.Schedule{Interval: "daily"},
+		Labels:           []string{"frontend"},
+	}}}
+	merged := m.Merge(existing, ecosystems, &Target{Repo: &github.Repository{Name: github.String("shop")}})
+	root := merged.Updates[0]
+	if !reflect.DeepEqual(root.Labels, []string{"frontend", "dependencies", "shop"}) {
+		t.Errorf("unexpected merged labels: %v", root.Labels)
+	}
+	// Without a team the reviewer is left out
+	if len(root.Reviewers) != 0 {
+		t.Errorf("unexpected merged reviewers: %v", root.Reviewers)
+	}
+	if root.CommitMessage == nil || root.CommitMessage.Prefix != "deps(/)" {
+		t.Errorf("unexpected merged commit-message: %+v", root.CommitMessage)
+	}
+}
+
+func TestMerger_variableErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "unknown variable",
+			files:   map[string]string{"npm/default.yml": "ecosystem: npm\nversion: 2\nupdates:\n- labels: [\"{{ .Repo.Owner }}\"]\n"},
+			wantErr: "can't evaluate field Owner",
+		},
+		{
+			name:    "malformed template",
+			files:   map[string]string{"npm/default.yml": "ecosystem: npm\nversion: 2\nupdates:\n- labels: [\"{{ .Team \"]\n"},
+			wantErr: "invalid template variable",
+		},
+		{
+			name:    "layer",
+			files:   map[string]string{"repos/web-app.yml": "update:\n  reviewers: [\"{{ .Owner }}\"]\n"},
+			wantErr: "can't evaluate field Owner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(writeTemplates(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMerger_UsesTeam(t *testing.T) {
+	m, err := New(writeTemplates(t, map[string]string{
+		"npm/default.yml": "ecosystem: npm\nversion: 2\nupdates:\n- labels: [\"{{ .Repo.Name }}\"]\n",
+	}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if m.UsesTeam() {
+		t.Error("UsesTeam() = true without a template referencing .Team")
+	}
+}
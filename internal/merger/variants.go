@@ -0,0 +1,156 @@
+package merger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/google/go-github/v50/github"
+)
+
+// variant is a template used instead of the default template of its
+// ecosystem for the repositories its selector matches
+type variant struct {
+	// Name is the template file relative to the templates directory,
+	// without extension
+	Name     string
+	When     selector
+	Template config.DependabotConfig
+}
+
+// selector chooses repositories by their attributes. A repository matches
+// when it matches every criterion that is set.
+type selector struct {
+	// Topics matches repositories with any of the topics
+	Topics []string `yaml:"topics,omitempty"`
+	// Visibility is public, private or internal
+	Visibility string `yaml:"visibility,omitempty"`
+	// Name is a glob matched against the repository name, e.g. svc-*
+	Name string `yaml:"name,omitempty"`
+	// Language is the primary language, compared case-insensitively
+	Language string `yaml:"language,omitempty"`
+}
+
+// validate checks that the selector sets a criterion and that each is valid
+func (s *selector) validate() error {
+	if len(s.Topics) == 0 && s.Visibility == "" && s.Name == "" && s.Language == "" {
+		return fmt.Errorf("when selector has no criteria")
+	}
+
+	switch s.Visibility {
+	case "", "public", "private", "internal":
+	default:
+		return fmt.Errorf("unknown visibility %q", s.Visibility)
+	}
+
+	if _, err := path.Match(s.Name, ""); err != nil {
+		return fmt.Errorf("invalid name pattern %q: %w", s.Name, err)
+	}
+	return nil
+}
+
+// matches reports whether repo is selected
+func (s *selector) matches(repo *github.Repository) bool {
+	if len(s.Topics) > 0 && !hasAnyTopic(repo, s.Topics) {
+		return false
+	}
+	if s.Visibility != "" && s.Visibility != visibility(repo) {
+		return false
+	}
+	if s.Name != "" {
+		if ok, _ := path.Match(s.Name, repo.GetName()); !ok {
+			return false
+		}
+	}
+	if s.Language != "" && !strings.EqualFold(s.Language, repo.GetLanguage()) {
+		return false
+	}
+	return true
+}
+
+// hasAnyTopic reports whether repo has one of topics
+func hasAnyTopic(repo *github.Repository, topics []string) bool {
+	for _, topic := range repo.Topics {
+		for _, want := range topics {
+			if topic == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visibility returns the visibility of repo. Older API responses only tell
+// whether it is private.
+func visibility(repo *github.Repository) string {
+	if v := repo.GetVisibility(); v != "" {
+		return strings.ToLower(v)
+	}
+	if repo.GetPrivate() {
+		return "private"
+	}
+	return "public"
+}
+
+// Selection fingerprints the attributes of repo templates depend on besides
+// its files: the topics, visibility and language that select variants and
+// layers, and the default branch variables can reference. Together with
+// Fingerprint it tells whether the templates may apply differently to a
+// repository than before.
+func Selection(repo *github.Repository) string {
+	topics := slices.Clone(repo.Topics)
+	slices.Sort(topics)
+
+	parts := []string{
+		strings.Join(topics, ","),
+		visibility(repo),
+		repo.GetLanguage(),
+		repo.GetDefaultBranch(),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Variants names the template applied to each of the ecosystems of repo,
+// as the template file relative to the templates directory without its
+// extension. Ecosystems without a template are left out.
+func (m *Merger) Variants(ecosystems []detector.Ecosystem, repo *github.Repository) []string {
+	var names []string
+	for _, eco := range ecosystems {
+		if _, name, ok := m.selectTemplate(eco.Name, repo); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// selectTemplate returns the template of an ecosystem for repo and its name:
+// the first variant whose selector matches, else the default template, else
+// the base
+func (m *Merger) selectTemplate(ecosystem string, repo *github.Repository) (config.DependabotConfig, string, bool) {
+	if repo != nil {
+		for _, v := range m.variants[ecosystem] {
+			if v.When.matches(repo) {
+				return v.Template, v.Name, true
+			}
+		}
+	}
+
+	if tmpl, ok := m.templates[ecosystem]; ok {
+		return tmpl, m.sources[ecosystem], true
+	}
+
+	// The base only stands in for ecosystems Dependabot knows, so detector
+	// names that are not valid package-ecosystem values are never written
+	if m.base != nil && config.IsEcosystem(ecosystem) {
+		if update, err := toUpdate(m.baseUpdate(ecosystem)); err == nil {
+			return config.DependabotConfig{Version: 2, Updates: []config.DependabotUpdate{update}}, baseName, true
+		}
+	}
+	return config.DependabotConfig{}, "", false
+}
@@ -0,0 +1,217 @@
+package merger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/google/go-github/v50/github"
+)
+
+func TestSelector_matches(t *testing.T) {
+	repo := &github.Repository{
+		Name:       github.String("svc-billing"),
+		Topics:     []string{"team-data", "backend"},
+		Visibility: github.String("internal"),
+		Language:   github.String("Python"),
+	}
+
+	tests := []struct {
+		name string
+		when selector
+		want bool
+	}{
+		{name: "any topic", when: selector{Topics: []string{"team-frontend", "team-data"}}, want: true},
+		{name: "no topic", when: selector{Topics: []string{"team-frontend"}}, want: false},
+		{name: "visibility", when: selector{Visibility: "internal"}, want: true},
+		{name: "other visibility", when: selector{Visibility: "private"}, want: false},
+		{name: "name glob", when: selector{Name: "svc-*"}, want: true},
+		{name: "other name", when: selector{Name: "lib-*"}, want: false},
+		{name: "language ignores case", when: selector{Language: "python"}, want: true},
+		{name: "all criteria", when: selector{Topics: []string{"backend"}, Name: "svc-*", Language: "Python"}, want: true},
+		{name: "one criterion fails", when: selector{Topics: []string{"backend"}, Language: "Go"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.when.matches(repo); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelector_visibilityFromPrivate(t *testing.T) {
+	private := selector{Visibility: "private"}
+	if !private.matches(&github.Repository{Private: github.Bool(true)}) {
+		t.Error("private repository without visibility should match private")
+	}
+	if private.matches(&github.Repository{}) {
+		t.Error("repository without visibility should be public")
+	}
+}
+
+func TestSelection(t *testing.T) {
+	repo := &github.Repository{
+		Name:     github.String("svc-billing"),
+		Topics:   []string{"team-data", "backend"},
+		Language: github.String("Go"),
+	}
+	base := Selection(repo)
+
+	reordered := *repo
+	reordered.Topics = []string{"backend", "team-data"}
+	if got := Selection(&reordered); got != base {
+		t.Errorf("Selection() should not depend on topic order")
+	}
+
+	for name, change := range map[string]func(r *github.Repository){
+		"topics":         func(r *github.Repository) { r.Topics = []string{"backend"} },
+		"visibility":     func(r *github.Repository) { r.Private = github.Bool(true) },
+		"language":       func(r *github.Repository) { r.Language = github.String("Rust") },
+		"default branch": func(r *github.Repository) { r.DefaultBranch = github.String("develop") },
+	} {
+		changed := *repo
+		change(&changed)
+		if Selection(&changed) == base {
+			t.Errorf("Selection() should change with the %s", name)
+		}
+	}
+}
+
+func TestMerger_variants(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"common/base.yml": "update:\n  schedule:\n    interval: daily\n  labels: [dependencies]\n",
+		"npm/default.yml": "ecosystem: npm\nversion: 2\nupdates:\n- labels: [javascript]\n",
+		"npm/frontend.yml": `
+ecosystem: npm
+when:
+  topics: [team-frontend]
+version: 2
+updates:
+- schedule:
+    interval: weekly
+`,
+		"npm/services.yml": `
+ecosystem: npm
+when:
+  name: svc-*
+  visibility: private
+version: 2
+updates:
+- labels: [service]
+`,
+	})
+
+	m, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ecosystems := []detector.Ecosystem{
+		{Name: "npm", Type: "npm", Directories: []string{"/"}},
+		{Name: "gomod", Type: "gomod", Directories: []string{"/"}},
+	}
+
+	tests := []struct {
+		name     string
+		repo     *github.Repository
+		variants []string
+		interval string
+		labels   []string
+	}{
+		{
+			name:     "default",
+			repo:     &github.Repository{Name: github.String("web-app")},
+			variants: []string{"npm/default", "common/base"},
+			interval: "daily",
+			labels:   []string{"dependencies", "javascript"},
+		},
+		{
+			name:     "topic",
+			repo:     &github.Repository{Name: github.String("web-app"), Topics: []string{"team-frontend"}},
+			variants: []string{"npm/frontend", "common/base"},
+			interval: "weekly",
+			labels:   []string{"dependencies"},
+		},
+		{
+			name:     "first match wins",
+			repo:     &github.Repository{Name: github.String("svc-cart"), Private: github.Bool(true), Topics: []string{"team-frontend"}},
+			variants: []string{"npm/frontend", "common/base"},
+			interval: "weekly",
+			labels:   []string{"dependencies"},
+		},
+		{
+			name:     "name and visibility",
+			repo:     &github.Repository{Name: github.String("svc-cart"), Private: github.Bool(true)},
+			variants: []string{"npm/services", "common/base"},
+			interval: "daily",
+			labels:   []string{"dependencies", "service"},
+		},
+		{
+			name:     "public service",
+			repo:     &github.Repository{Name: github.String("svc-docs")},
+			variants: []string{"npm/default", "common/base"},
+			interval: "daily",
+			labels:   []string{"dependencies", "javascript"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Variants(ecosystems, tt.repo); !reflect.DeepEqual(got, tt.variants) {
+				t.Errorf("Variants() = %v, want %v", got, tt.variants)
+			}
+
+			// Updates are sorted by ecosystem
+			npm := m.Merge(nil, ecosystems, &Target{Repo: tt.repo}).Updates[1]
+			if npm.PackageEcosystem != "npm" || npm.Schedule.Interval != tt.interval || !reflect.DeepEqual(npm.Labels, tt.labels) {
+				t.Errorf("unexpected npm update: %+v", npm)
+			}
+		})
+	}
+}
+
+func TestMerger_variantErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "variant without selector",
+			files:   map[string]string{"npm/frontend.yml": "ecosystem: npm\nversion: 2\nupdates:\n- labels: [x]\n"},
+			wantErr: "variant has no when selector",
+		},
+		{
+			name:    "default with selector",
+			files:   map[string]string{"npm/default.yml": "ecosystem: npm\nwhen:\n  name: web-*\nversion: 2\nupdates:\n- labels: [x]\n"},
+			wantErr: "cannot have a when selector",
+		},
+		{
+			name:    "empty selector",
+			files:   map[string]string{"npm/frontend.yml": "ecosystem: npm\nwhen: {}\nversion: 2\nupdates:\n- labels: [x]\n"},
+			wantErr: "no criteria",
+		},
+		{
+			name:    "unknown visibility",
+			files:   map[string]string{"npm/frontend.yml": "ecosystem: npm\nwhen:\n  visibility: secret\nversion: 2\nupdates:\n- labels: [x]\n"},
+			wantErr: `unknown visibility "secret"`,
+		},
+		{
+			name:    "invalid glob",
+			files:   map[string]string{"npm/frontend.yml": "ecosystem: npm\nwhen:\n  name: \"svc-[\"\nversion: 2\nupdates:\n- labels: [x]\n"},
+			wantErr: "invalid name pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(writeTemplates(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,12 +4,16 @@ package reporter
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v50/github"
+	"github.com/enthus-appdev/dependabot-config-manager/internal/config"
 	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
 )
 
@@ -33,6 +37,7 @@ type Summary struct {
 	FailedRepositories     int            `json:"failed_repositories"`
 	CoveragePercentage     float64        `json:"coverage_percentage"`
 	EcosystemBreakdown     map[string]int `json:"ecosystem_breakdown"`
+	APICalls               int            `json:"api_calls"`
 }
 
 // RepositoryDetail contains details about a specific repository
@@ -46,6 +51,14 @@ type RepositoryDetail struct {
 	Error              string               `json:"error,omitempty"`
 	URL                string               `json:"url"`
 	Topics             []string             `json:"topics,omitempty"`
+	Templates          []string             `json:"templates,omitempty"`
+	Pruned             []string             `json:"pruned,omitempty"`
+	Diff               string               `json:"diff,omitempty"`
+	Changes            []config.Difference  `json:"changes,omitempty"`
+	StartedAt          time.Time            `json:"started_at,omitzero"`
+	FinishedAt         time.Time            `json:"finished_at,omitzero"`
+	Duration           string               `json:"duration,omitempty"`
+	APICalls           int                  `json:"api_calls"`
 }
 
 // Error represents an error that occurred during processing
@@ -55,14 +68,27 @@ type Error struct {
 	Timestamp  time.Time `json:"timestamp"`
 }
 
-// Reporter handles report generation and output
+// Reporter handles report generation and output. It is safe for concurrent
+// use by the goroutines processing repositories.
 type Reporter struct {
+	mu            sync.Mutex
 	startTime     time.Time
 	report        *Report
 	outputDir     string
 	verboseOutput bool
 }
 
+// Run records the outcome of processing a single repository, together with
+// when processing started and finished and how many API calls it took
+type Run struct {
+	reporter  *Reporter
+	repo      *github.Repository
+	started   time.Time
+	apiCalls  func() int
+	templates []string
+	pruned    []string
+}
+
 // New creates a new reporter
 func New(org, outputDir string, verbose bool) *Reporter {
 	return &Reporter{
@@ -83,7 +109,73 @@ func New(org, outputDir string, verbose bool) *Reporter {
 
 // AddRepository adds a repository to the report
 func (r *Reporter) AddRepository(repo *github.Repository, ecosystems []detector.Ecosystem, status string, skipReason string, err error) {
-	detail := RepositoryDetail{
+	r.addDetail(newDetail(repo, ecosystems, status, skipReason), err)
+}
+
+// StartRepository marks the start of processing repo. apiCalls reports the
+// number of API calls made for it so far and may be nil.
+func (r *Reporter) StartRepository(repo *github.Repository, apiCalls func() int) *Run {
+	return &Run{
+		reporter: r,
+		repo:     repo,
+		started:  time.Now(),
+		apiCalls: apiCalls,
+	}
+}
+
+// AddAPICalls records API calls made for the run as a whole rather than
+// for a single repository, like listing the repositories
+func (r *Reporter) AddAPICalls(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Summary.APICalls += n
+}
+
+// Processed records a successfully processed repository along with the
+// diff and the changed fields of the proposed configuration, if any
+func (run *Run) Processed(ecosystems []detector.Ecosystem, wasUpdated bool, diff string, changes []config.Difference) {
+	detail := newProcessedDetail(run.repo, ecosystems, wasUpdated, diff, changes)
+	run.finish(detail, nil)
+}
+
+// SetTemplates records the templates applied to the repository
+func (run *Run) SetTemplates(templates []string) {
+	run.templates = templates
+}
+
+// SetPruned records the updates removed from the configuration because
+// their ecosystem or directory no longer exists
+func (run *Run) SetPruned(pruned []string) {
+	run.pruned = pruned
+}
+
+// Skipped records a skipped repository
+func (run *Run) Skipped(reason string) {
+	run.finish(newDetail(run.repo, nil, "skipped", reason), nil)
+}
+
+// Failed records a repository that could not be processed
+func (run *Run) Failed(err error) {
+	run.finish(newDetail(run.repo, nil, "failed", ""), err)
+}
+
+// finish adds the timing to a repository entry and records it
+func (run *Run) finish(detail RepositoryDetail, err error) {
+	detail.StartedAt = run.started
+	detail.FinishedAt = time.Now()
+	detail.Duration = detail.FinishedAt.Sub(run.started).Round(time.Millisecond).String()
+	if run.apiCalls != nil {
+		detail.APICalls = run.apiCalls()
+	}
+	detail.Templates = run.templates
+	detail.Pruned = run.pruned
+	run.reporter.addDetail(detail, err)
+}
+
+// newDetail creates the report entry for a repository
+func newDetail(repo *github.Repository, ecosystems []detector.Ecosystem, status string, skipReason string) RepositoryDetail {
+	return RepositoryDetail{
 		Name:               repo.GetName(),
 		Status:             status,
 		DetectedEcosystems: ecosystems,
@@ -91,25 +183,32 @@ func (r *Reporter) AddRepository(repo *github.Repository, ecosystems []detector.
 		Topics:             repo.Topics,
 		SkipReason:         skipReason,
 	}
+}
+
+// addDetail records a repository entry and updates the summary
+func (r *Reporter) addDetail(detail RepositoryDetail, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
 	if err != nil {
 		detail.Error = err.Error()
 		r.report.Errors = append(r.report.Errors, Error{
-			Repository: repo.GetName(),
+			Repository: detail.Name,
 			Message:    err.Error(),
 			Timestamp:  time.Now(),
 		})
 	}
 
 	// Update ecosystem breakdown
-	for _, eco := range ecosystems {
+	for _, eco := range detail.DetectedEcosystems {
 		r.report.Summary.EcosystemBreakdown[eco.Name]++
 	}
 
 	// Update summary counters
 	r.report.Summary.TotalRepositories++
+	r.report.Summary.APICalls += detail.APICalls
 
-	switch status {
+	switch detail.Status {
 	case "configured":
 		r.report.Summary.ConfiguredRepositories++
 		detail.HasExistingConfig = true
@@ -127,14 +226,23 @@ func (r *Reporter) AddRepository(repo *github.Repository, ecosystems []detector.
 	r.report.RepositoryDetails = append(r.report.RepositoryDetails, detail)
 }
 
-// AddProcessedRepository adds a successfully processed repository
-func (r *Reporter) AddProcessedRepository(repo *github.Repository, ecosystems []detector.Ecosystem, _, wasUpdated bool) {
+// AddProcessedRepository adds a successfully processed repository along with
+// the diff and the changed fields of the proposed configuration, if any
+func (r *Reporter) AddProcessedRepository(repo *github.Repository, ecosystems []detector.Ecosystem, _, wasUpdated bool, diff string, changes []config.Difference) {
+	r.addDetail(newProcessedDetail(repo, ecosystems, wasUpdated, diff, changes), nil)
+}
+
+// newProcessedDetail creates the report entry for a processed repository
+func newProcessedDetail(repo *github.Repository, ecosystems []detector.Ecosystem, wasUpdated bool, diff string, changes []config.Difference) RepositoryDetail {
 	status := "configured"
 	if wasUpdated {
 		status = "updated"
 	}
 
-	r.AddRepository(repo, ecosystems, status, "", nil)
+	detail := newDetail(repo, ecosystems, status, "")
+	detail.Diff = diff
+	detail.Changes = changes
+	return detail
 }
 
 // AddSkippedRepository adds a skipped repository
@@ -149,6 +257,14 @@ func (r *Reporter) AddFailedRepository(repo *github.Repository, err error) {
 
 // Finalize finalizes the report with calculated statistics
 func (r *Reporter) Finalize() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finalize()
+}
+
+// finalize calculates the statistics; the caller must hold r.mu
+func (r *Reporter) finalize() {
 	r.report.Duration = time.Since(r.startTime).String()
 	r.report.Summary.ProcessedRepositories = r.report.Summary.TotalRepositories -
 		r.report.Summary.SkippedRepositories - r.report.Summary.FailedRepositories
@@ -161,7 +277,10 @@ func (r *Reporter) Finalize() {
 
 // SaveReport saves the report to a file
 func (r *Reporter) SaveReport(format string) error {
-	r.Finalize()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finalize()
 
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(r.outputDir, 0755); err != nil {
@@ -224,7 +343,8 @@ func (r *Reporter) saveMarkdown(timestamp string) error {
 	sb.WriteString(fmt.Sprintf("- **Updated:** %d\n", r.report.Summary.UpdatedRepositories))
 	sb.WriteString(fmt.Sprintf("- **Skipped:** %d\n", r.report.Summary.SkippedRepositories))
 	sb.WriteString(fmt.Sprintf("- **Failed:** %d\n", r.report.Summary.FailedRepositories))
-	sb.WriteString(fmt.Sprintf("- **Coverage:** %.1f%%\n\n", r.report.Summary.CoveragePercentage))
+	sb.WriteString(fmt.Sprintf("- **Coverage:** %.1f%%\n", r.report.Summary.CoveragePercentage))
+	sb.WriteString(fmt.Sprintf("- **API Calls:** %d\n\n", r.report.Summary.APICalls))
 
 	// Ecosystem breakdown
 	if len(r.report.Summary.EcosystemBreakdown) > 0 {
@@ -254,6 +374,20 @@ func (r *Reporter) saveMarkdown(timestamp string) error {
 				sb.WriteString(fmt.Sprintf(" - %s", strings.Join(ecosystems, ", ")))
 			}
 			sb.WriteString("\n")
+			for _, change := range repo.Changes {
+				sb.WriteString(fmt.Sprintf("  - `%s`\n", change))
+			}
+			for _, update := range repo.Pruned {
+				sb.WriteString(fmt.Sprintf("  - 🗑️ pruned `%s`\n", update))
+			}
+			if repo.Diff != "" {
+				sb.WriteString("\n  <details><summary>Proposed changes</summary>\n\n")
+				sb.WriteString("  ```diff\n")
+				for _, line := range strings.Split(strings.TrimSuffix(repo.Diff, "\n"), "\n") {
+					sb.WriteString("  " + line + "\n")
+				}
+				sb.WriteString("  ```\n\n  </details>\n\n")
+			}
 		}
 		sb.WriteString("\n")
 	}
@@ -286,6 +420,18 @@ func (r *Reporter) saveMarkdown(timestamp string) error {
 		sb.WriteString("\n")
 	}
 
+	// Per-repository timing
+	if timed := r.timedRepositories(); len(timed) > 0 {
+		sb.WriteString("## Repository Timing\n\n")
+		sb.WriteString("| Repository | Status | Template | Started | Duration | API Calls |\n")
+		sb.WriteString("|------------|--------|----------|---------|----------|-----------|\n")
+		for _, repo := range timed {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %d |\n",
+				repo.Name, repo.Status, strings.Join(repo.Templates, ", "), repo.StartedAt.Format(time.RFC3339), repo.Duration, repo.APICalls))
+		}
+		sb.WriteString("\n")
+	}
+
 	// Recommendations
 	sb.WriteString("## Recommendations\n\n")
 
@@ -341,6 +487,10 @@ func (r *Reporter) generateHTML() string {
         table { width: 100%%; border-collapse: collapse; margin: 20px 0; }
         th, td { padding: 10px; text-align: left; border-bottom: 1px solid #ddd; }
         th { background: #f5f5f5; }
+        pre.diff { background: #f8f8f8; padding: 10px; border-radius: 3px; overflow-x: auto; }
+        .diff .added { color: green; }
+        .diff .removed { color: red; }
+        .diff .hunk { color: #0086b3; }
     </style>
 </head>
 <body>
@@ -355,8 +505,9 @@ func (r *Reporter) generateHTML() string {
         <div class="metric warning">Skipped: %d</div>
         <div class="metric error">Failed: %d</div>
         <div class="metric">Coverage: %.1f%%</div>
+        <div class="metric">API Calls: %d</div>
     </div>
-</body>
+%s%s</body>
 </html>`,
 		r.report.Organization,
 		r.report.Timestamp.Format(time.RFC3339),
@@ -366,9 +517,93 @@ func (r *Reporter) generateHTML() string {
 		r.report.Summary.SkippedRepositories,
 		r.report.Summary.FailedRepositories,
 		r.report.Summary.CoveragePercentage,
+		r.report.Summary.APICalls,
+		r.generateHTMLTiming(),
+		r.generateHTMLDiffs(),
 	)
 }
 
+// generateHTMLTiming renders how long each repository took to process
+func (r *Reporter) generateHTMLTiming() string {
+	timed := r.timedRepositories()
+	if len(timed) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("    <h2>Repository Timing</h2>\n")
+	sb.WriteString("    <table>\n        <tr><th>Repository</th><th>Status</th><th>Template</th><th>Started</th><th>Duration</th><th>API Calls</th></tr>\n")
+	for _, repo := range timed {
+		sb.WriteString(fmt.Sprintf("        <tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%d</td></tr>\n",
+			html.EscapeString(repo.Name), html.EscapeString(repo.Status), html.EscapeString(strings.Join(repo.Templates, ", ")),
+			repo.StartedAt.Format(time.RFC3339), html.EscapeString(repo.Duration), repo.APICalls))
+	}
+	sb.WriteString("    </table>\n")
+
+	return sb.String()
+}
+
+// generateHTMLDiffs renders the proposed configuration change of every updated repository
+func (r *Reporter) generateHTMLDiffs() string {
+	var sb strings.Builder
+
+	for _, repo := range r.filterByStatus("updated") {
+		if repo.Diff == "" {
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteString("    <h2>Proposed Changes</h2>\n")
+		}
+		sb.WriteString(fmt.Sprintf("    <details>\n        <summary><a href=\"%s\">%s</a></summary>\n",
+			html.EscapeString(repo.URL), html.EscapeString(repo.Name)))
+		if len(repo.Changes) > 0 || len(repo.Pruned) > 0 {
+			sb.WriteString("        <ul class=\"changes\">\n")
+			for _, change := range repo.Changes {
+				sb.WriteString(fmt.Sprintf("            <li><code>%s</code></li>\n", html.EscapeString(change.String())))
+			}
+			for _, update := range repo.Pruned {
+				sb.WriteString(fmt.Sprintf("            <li>pruned <code>%s</code></li>\n", html.EscapeString(update)))
+			}
+			sb.WriteString("        </ul>\n")
+		}
+		sb.WriteString("        <pre class=\"diff\">")
+		for _, line := range strings.Split(strings.TrimSuffix(repo.Diff, "\n"), "\n") {
+			class := ""
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				class = "hunk"
+			case strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++"):
+				class = "added"
+			case strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---"):
+				class = "removed"
+			}
+			if class != "" {
+				sb.WriteString(fmt.Sprintf("<span class=\"%s\">%s</span>\n", class, html.EscapeString(line)))
+			} else {
+				sb.WriteString(html.EscapeString(line) + "\n")
+			}
+		}
+		sb.WriteString("</pre>\n    </details>\n")
+	}
+
+	return sb.String()
+}
+
+// timedRepositories returns the repositories with timing information,
+// ordered by when their processing started
+func (r *Reporter) timedRepositories() []RepositoryDetail {
+	var timed []RepositoryDetail
+	for _, repo := range r.report.RepositoryDetails {
+		if !repo.StartedAt.IsZero() {
+			timed = append(timed, repo)
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].StartedAt.Before(timed[j].StartedAt)
+	})
+	return timed
+}
+
 // filterByStatus filters repositories by status
 func (r *Reporter) filterByStatus(status string) []RepositoryDetail {
 	var filtered []RepositoryDetail
@@ -382,7 +617,10 @@ func (r *Reporter) filterByStatus(status string) []RepositoryDetail {
 
 // PrintSummary prints a summary to stdout
 func (r *Reporter) PrintSummary() {
-	r.Finalize()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finalize()
 
 	fmt.Println("\n📊 Synchronization Summary")
 	fmt.Println("═══════════════════════════")
@@ -393,6 +631,7 @@ func (r *Reporter) PrintSummary() {
 	fmt.Printf("❌ Failed: %d\n", r.report.Summary.FailedRepositories)
 	fmt.Printf("📈 Coverage: %.1f%%\n", r.report.Summary.CoveragePercentage)
 	fmt.Printf("⏱️  Duration: %s\n", r.report.Duration)
+	fmt.Printf("🌐 API Calls: %d\n", r.report.Summary.APICalls)
 
 	if len(r.report.Summary.EcosystemBreakdown) > 0 {
 		fmt.Println("\n🔧 Detected Ecosystems:")
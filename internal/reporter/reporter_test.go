@@ -0,0 +1,142 @@
+package reporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/enthus-appdev/dependabot-config-manager/internal/detector"
+	"github.com/google/go-github/v50/github"
+)
+
+func TestReporter_ConcurrentRuns(t *testing.T) {
+	r := New("acme", t.TempDir(), false)
+
+	const repos = 50
+	var wg sync.WaitGroup
+	for i := 0; i < repos; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			repo := &github.Repository{Name: github.String(fmt.Sprintf("repo-%d", i))}
+			run := r.StartRepository(repo, func() int { return 3 })
+
+			switch i % 3 {
+			case 0:
+				run.Processed([]detector.Ecosystem{{Name: "npm"}}, true, "", nil)
+			case 1:
+				run.Skipped("no supported ecosystems detected")
+			default:
+				run.Failed(errors.New("boom"))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	r.Finalize()
+
+	summary := r.report.Summary
+	if summary.TotalRepositories != repos || len(r.report.RepositoryDetails) != repos {
+		t.Errorf("expected %d repositories, got %d (%d details)", repos, summary.TotalRepositories, len(r.report.RepositoryDetails))
+	}
+	if summary.UpdatedRepositories != 17 || summary.SkippedRepositories != 17 || summary.FailedRepositories != 16 {
+		t.Errorf("unexpected counters: %+v", summary)
+	}
+	if summary.EcosystemBreakdown["npm"] != 17 {
+		t.Errorf("expected 17 npm repositories, got %d", summary.EcosystemBreakdown["npm"])
+	}
+	if summary.APICalls != 3*repos {
+		t.Errorf("expected %d API calls, got %d", 3*repos, summary.APICalls)
+	}
+	if len(r.report.Errors) != 16 {
+		t.Errorf("expected 16 errors, got %d", len(r.report.Errors))
+	}
+
+	for _, detail := range r.report.RepositoryDetails {
+		if detail.StartedAt.IsZero() || detail.FinishedAt.Before(detail.StartedAt) || detail.Duration == "" {
+			t.Errorf("%s: missing timing: %+v", detail.Name, detail)
+		}
+		if detail.APICalls != 3 {
+			t.Errorf("%s: expected 3 API calls, got %d", detail.Name, detail.APICalls)
+		}
+	}
+}
+
+func TestReporter_AddAPICalls(t *testing.T) {
+	r := New("acme", t.TempDir(), false)
+
+	r.AddAPICalls(2)
+	run := r.StartRepository(&github.Repository{Name: github.String("web-app")}, func() int { return 3 })
+	run.Skipped("no supported ecosystems detected")
+
+	if got := r.report.Summary.APICalls; got != 5 {
+		t.Errorf("expected 5 API calls, got %d", got)
+	}
+}
+
+func TestReporter_SaveReport_timing(t *testing.T) {
+	dir := t.TempDir()
+	r := New("acme", dir, false)
+
+	run := r.StartRepository(&github.Repository{Name: github.String("web-app")}, func() int { return 7 })
+	run.SetTemplates([]string{"npm/frontend", "docker/default"})
+	run.Processed([]detector.Ecosystem{{Name: "npm"}}, true, "", nil)
+
+	if err := r.SaveReport("all"); err != nil {
+		t.Fatalf("SaveReport() error = %v", err)
+	}
+
+	for pattern, want := range map[string]string{
+		"*.json": `"api_calls": 7`,
+		"*.md":   "| web-app | updated | npm/frontend, docker/default |",
+		"*.html": "<td>web-app</td><td>updated</td><td>npm/frontend, docker/default</td>",
+	} {
+		files, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("expected one %s report, got %v (%v)", pattern, files, err)
+		}
+		data, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s report should contain %q, got:\n%s", pattern, want, data)
+		}
+	}
+}
+
+func TestReporter_SaveReport_pruned(t *testing.T) {
+	dir := t.TempDir()
+	r := New("acme", dir, false)
+
+	run := r.StartRepository(&github.Repository{Name: github.String("web-app")}, nil)
+	run.SetPruned([]string{"npm /legacy"})
+	run.Processed([]detector.Ecosystem{{Name: "npm"}}, true, "--- a\n+++ b\n", nil)
+
+	if err := r.SaveReport("all"); err != nil {
+		t.Fatalf("SaveReport() error = %v", err)
+	}
+
+	for pattern, want := range map[string]string{
+		"*.json": `"pruned": [`,
+		"*.md":   "  - 🗑️ pruned `npm /legacy`",
+		"*.html": "<li>pruned <code>npm /legacy</code></li>",
+	} {
+		files, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("expected one %s report, got %v (%v)", pattern, files, err)
+		}
+		data, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s report should contain %q, got:\n%s", pattern, want, data)
+		}
+	}
+}
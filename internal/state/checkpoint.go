@@ -0,0 +1,133 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Checkpoint records which repositories a run has finished, so an
+// interrupted run can be resumed with the rest. It is safe for concurrent
+// use.
+type Checkpoint struct {
+	path string
+	org  string
+
+	mu        sync.Mutex
+	startedAt time.Time
+	done      map[string]bool
+
+	// saveMu keeps concurrent saves from replacing a newer file with an
+	// older one
+	saveMu sync.Mutex
+}
+
+// checkpointFile is the on-disk format of a Checkpoint
+type checkpointFile struct {
+	Organization string    `json:"organization"`
+	StartedAt    time.Time `json:"started_at"`
+	Done         []string  `json:"done"`
+}
+
+// NewCheckpoint starts an empty checkpoint for a run over org, stored at path
+func NewCheckpoint(path, org string) *Checkpoint {
+	return &Checkpoint{
+		path:      path,
+		org:       org,
+		startedAt: time.Now().UTC(),
+		done:      make(map[string]bool),
+	}
+}
+
+// LoadCheckpoint reads the checkpoint of an interrupted run over org. It
+// returns an empty checkpoint if there is none.
+func LoadCheckpoint(path, org string) (*Checkpoint, error) {
+	c := NewCheckpoint(path, org)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var f checkpointFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if f.Organization != org {
+		return nil, fmt.Errorf("checkpoint %s belongs to organization %q, not %q", path, f.Organization, org)
+	}
+
+	c.startedAt = f.StartedAt
+	for _, repo := range f.Done {
+		c.done[repo] = true
+	}
+	return c, nil
+}
+
+// Done marks a repository as finished and returns how many are
+func (c *Checkpoint) Done(repo string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done[repo] = true
+	return len(c.done)
+}
+
+// IsDone reports whether a repository was finished
+func (c *Checkpoint) IsDone(repo string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.done[repo]
+}
+
+// Len returns the number of finished repositories
+func (c *Checkpoint) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.done)
+}
+
+// Save writes the checkpoint file, replacing it atomically
+func (c *Checkpoint) Save() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	f := checkpointFile{
+		Organization: c.org,
+		StartedAt:    c.startedAt,
+		Done:         make([]string, 0, len(c.done)),
+	}
+	for repo := range c.done {
+		f.Done = append(f.Done, repo)
+	}
+	c.mu.Unlock()
+	sort.Strings(f.Done)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err := writeFile(c.path, data); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the checkpoint file once the run completed
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint_SaveLoadRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c := NewCheckpoint(path, "acme")
+	c.Done("web-app")
+	c.Done("api-service")
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadCheckpoint(path, "acme")
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+	if loaded.Len() != 2 || !loaded.IsDone("web-app") || loaded.IsDone("legacy-docs") {
+		t.Errorf("expected web-app and api-service done after reload, got %d done", loaded.Len())
+	}
+
+	if _, err := LoadCheckpoint(path, "other-org"); err == nil {
+		t.Error("expected an error resuming the checkpoint of another organization")
+	}
+
+	if err := loaded.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint file to be removed, got %v", err)
+	}
+	if err := loaded.Remove(); err != nil {
+		t.Errorf("Remove() of a missing file error = %v", err)
+	}
+
+	// Without a checkpoint there is nothing to skip
+	empty, err := LoadCheckpoint(path, "acme")
+	if err != nil || empty.Len() != 0 {
+		t.Errorf("LoadCheckpoint() of a missing file = %d done, %v", empty.Len(), err)
+	}
+}
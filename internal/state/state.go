@@ -0,0 +1,149 @@
+// Package state remembers what earlier sync runs saw in each repository so
+// unchanged repositories can be skipped.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// version is the format of the state file; files of another version are
+// ignored and rebuilt
+const version = 1
+
+// Repository is what the last run recorded about a repository
+type Repository struct {
+	// HeadSHA is the commit the default branch pointed at
+	HeadSHA string `json:"head_sha"`
+	// TemplateHash fingerprints the organization templates in effect and
+	// the repository attributes they depend on
+	TemplateHash string `json:"template_hash"`
+	// Ecosystems lists the detected ecosystems
+	Ecosystems []string `json:"ecosystems,omitempty"`
+	// ConfigHash is the hash of the configuration written or found, empty
+	// when the repository has none
+	ConfigHash string `json:"config_hash,omitempty"`
+	// SyncedAt is when the repository was last processed
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// Store holds the state of all repositories and persists it to a JSON file.
+// It is safe for concurrent use.
+type Store struct {
+	path string
+
+	mu           sync.Mutex
+	repositories map[string]Repository
+}
+
+// file is the on-disk format of a Store
+type file struct {
+	Version      int                   `json:"version"`
+	Repositories map[string]Repository `json:"repositories"`
+}
+
+// Load reads the state file at path. A missing file yields an empty store.
+func Load(path string) (*Store, error) {
+	s := &Store{
+		path:         path,
+		repositories: make(map[string]Repository),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if f.Version == version && f.Repositories != nil {
+		s.repositories = f.Repositories
+	}
+
+	return s, nil
+}
+
+// Get returns the recorded state of a repository
+func (s *Store) Get(repo string) (Repository, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repositories[repo]
+	return r, ok
+}
+
+// Put records the state of a repository
+func (s *Store) Put(repo string, r Repository) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repositories[repo] = r
+}
+
+// Unchanged reports whether a repository was processed before at the same
+// head commit and with the same templates
+func (s *Store) Unchanged(repo, headSHA, templateHash string) bool {
+	r, ok := s.Get(repo)
+	return ok && headSHA != "" && r.HeadSHA == headSHA && r.TemplateHash == templateHash
+}
+
+// Save writes the state file, replacing it atomically
+func (s *Store) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(file{Version: version, Repositories: s.repositories}, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := writeFile(s.path, data); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// writeFile replaces the file at path atomically, creating its directory if
+// needed, so a crash never leaves a partial file behind
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Hash returns the hex SHA-256 of content, or an empty string for no content
+func Hash(content []byte) string {
+	if content == nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() of a missing file error = %v", err)
+	}
+	if _, ok := s.Get("web-app"); ok {
+		t.Fatal("expected an empty store")
+	}
+
+	s.Put("web-app", Repository{HeadSHA: "abc", TemplateHash: "t1", Ecosystems: []string{"npm"}, ConfigHash: Hash([]byte("version: 2\n"))})
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	r, ok := loaded.Get("web-app")
+	if !ok || r.HeadSHA != "abc" || len(r.Ecosystems) != 1 || r.ConfigHash == "" {
+		t.Errorf("Get() = %+v, %v after reload", r, ok)
+	}
+}
+
+func TestStore_Unchanged(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("web-app", Repository{HeadSHA: "abc", TemplateHash: "t1"})
+
+	tests := []struct {
+		name     string
+		repo     string
+		head     string
+		template string
+		want     bool
+	}{
+		{name: "same head and templates", repo: "web-app", head: "abc", template: "t1", want: true},
+		{name: "new commit", repo: "web-app", head: "def", template: "t1"},
+		{name: "changed templates", repo: "web-app", head: "abc", template: "t2"},
+		{name: "unknown repository", repo: "api-service", head: "abc", template: "t1"},
+		{name: "unknown head", repo: "web-app", head: "", template: "t1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Unchanged(tt.repo, tt.head, tt.template); got != tt.want {
+				t.Errorf("Unchanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_IgnoresOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "repositories": {"web-app": {"head_sha": "abc"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, ok := s.Get("web-app"); ok {
+		t.Error("expected state of another version to be ignored")
+	}
+}
@@ -0,0 +1,171 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// UnifiedDiff renders a unified diff between two texts. It returns an empty
+// string when they are identical.
+func UnifiedDiff(fromName, toName string, from, to []byte) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	// Record the position of every op in both files
+	fromLine := make([]int, len(ops)+1)
+	toLine := make([]int, len(ops)+1)
+	var changes []int
+	for i, op := range ops {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if op.kind != '+' {
+			fromLine[i+1]++
+		}
+		if op.kind != '-' {
+			toLine[i+1]++
+		}
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+
+	for i := 0; i < len(changes); {
+		// Extend the hunk while the context of the next change overlaps or
+		// touches its own
+		j := i
+		for j+1 < len(changes) && changes[j+1]-diffContext <= changes[j]+diffContext+1 {
+			j++
+		}
+
+		start := changes[i] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[j] + diffContext + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+			hunkRange(fromLine[start], fromLine[end]-fromLine[start]),
+			hunkRange(toLine[start], toLine[end]-toLine[start]),
+		))
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+
+		i = j + 1
+	}
+
+	return sb.String()
+}
+
+// hunkRange formats the start,count pair of a hunk header. start is the
+// zero-based offset of the hunk's first line.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines computes a minimal line edit script using the longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	return ops
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// ANSI escape sequences used by ColorizeDiff
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+)
+
+// ColorizeDiff adds ANSI colors to a unified diff for terminal output
+func ColorizeDiff(diff string) string {
+	if diff == "" {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case i < 2 && (strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++")):
+			lines[i] = ansiBold + line + ansiReset
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = ansiCyan + line + ansiReset
+		case strings.HasPrefix(line, "-"):
+			lines[i] = ansiRed + line + ansiReset
+		case strings.HasPrefix(line, "+"):
+			lines[i] = ansiGreen + line + ansiReset
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
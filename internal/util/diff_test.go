@@ -0,0 +1,108 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		expected string
+	}{
+		{
+			name:     "identical",
+			from:     "a\nb\n",
+			to:       "a\nb\n",
+			expected: "",
+		},
+		{
+			name: "new file",
+			from: "",
+			to:   "a\nb\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -0,0 +1,2 @@\n" +
+				"+a\n" +
+				"+b\n",
+		},
+		{
+			name: "changed line with context",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			to:   "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -2,7 +2,7 @@\n" +
+				" 2\n" +
+				" 3\n" +
+				" 4\n" +
+				"-5\n" +
+				"+five\n" +
+				" 6\n" +
+				" 7\n" +
+				" 8\n",
+		},
+		{
+			name: "touching hunks",
+			from: "a\n1\n2\n3\n4\n5\n6\nb\n",
+			to:   "A\n1\n2\n3\n4\n5\n6\nB\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,8 +1,8 @@\n" +
+				"-a\n" +
+				"+A\n" +
+				" 1\n" +
+				" 2\n" +
+				" 3\n" +
+				" 4\n" +
+				" 5\n" +
+				" 6\n" +
+				"-b\n" +
+				"+B\n",
+		},
+		{
+			name: "separate hunks",
+			from: "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			to:   "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,4 +1,4 @@\n" +
+				"-a\n" +
+				"+A\n" +
+				" 1\n" +
+				" 2\n" +
+				" 3\n" +
+				"@@ -6,4 +6,4 @@\n" +
+				" 5\n" +
+				" 6\n" +
+				" 7\n" +
+				"-b\n" +
+				"+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("old", "new", []byte(tt.from), []byte(tt.to))
+			if got != tt.expected {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestColorizeDiff(t *testing.T) {
+	diff := "--- old\n+++ new\n@@ -1 +1 @@\n-a\n+b\n c\n"
+
+	expected := "\033[1m--- old\033[0m\n" +
+		"\033[1m+++ new\033[0m\n" +
+		"\033[36m@@ -1 +1 @@\033[0m\n" +
+		"\033[31m-a\033[0m\n" +
+		"\033[32m+b\033[0m\n" +
+		" c\n"
+
+	if got := ColorizeDiff(diff); got != expected {
+		t.Errorf("ColorizeDiff() = %q, want %q", got, expected)
+	}
+
+	if got := ColorizeDiff(""); got != "" {
+		t.Errorf("ColorizeDiff(\"\") = %q, want empty", got)
+	}
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"context"
+	"sync"
+)
+
+// Semaphore limits how many operations run at once. Unlike a buffered
+// channel its width can change while it is in use; shrinking it lets the
+// operations already running finish and holds back new ones.
+type Semaphore struct {
+	mu      sync.Mutex
+	width   int
+	inUse   int
+	waiters []chan struct{}
+
+	// widthFunc, if set, is asked for the width on every Acquire and Release
+	widthFunc func() int
+}
+
+// NewSemaphore creates a semaphore admitting width operations at once
+func NewSemaphore(width int) *Semaphore {
+	return &Semaphore{width: max(width, 1)}
+}
+
+// NewAdaptiveSemaphore creates a semaphore whose width is taken from width
+// whenever an operation starts or ends, so it follows changing conditions
+// such as rate limits while in use
+func NewAdaptiveSemaphore(width func() int) *Semaphore {
+	return &Semaphore{width: max(width(), 1), widthFunc: width}
+}
+
+// Acquire blocks until an operation may start or ctx is done
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	s.mu.Lock()
+	s.resize()
+	if s.inUse < s.width && len(s.waiters) == 0 {
+		s.inUse++
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	s.waiters = append(s.waiters, ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		select {
+		case <-ready:
+			// Admitted while giving up; hand the slot on
+			s.inUse--
+			s.admit()
+		default:
+			s.removeWaiter(ready)
+		}
+		return ctx.Err()
+	}
+}
+
+// Release ends an operation started with Acquire
+func (s *Semaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inUse--
+	s.resize()
+	s.admit()
+}
+
+// SetWidth changes how many operations may run at once; it is at least one
+func (s *Semaphore) SetWidth(width int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.width = max(width, 1)
+	s.admit()
+}
+
+// Width returns how many operations may currently run at once
+func (s *Semaphore) Width() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.width
+}
+
+// resize takes the width from widthFunc, if set; the caller must hold s.mu
+func (s *Semaphore) resize() {
+	if s.widthFunc != nil {
+		s.width = max(s.widthFunc(), 1)
+	}
+}
+
+// admit wakes waiters while there is room; the caller must hold s.mu
+func (s *Semaphore) admit() {
+	for s.inUse < s.width && len(s.waiters) > 0 {
+		s.inUse++
+		close(s.waiters[0])
+		s.waiters = s.waiters[1:]
+	}
+}
+
+// removeWaiter drops a waiter that gave up; the caller must hold s.mu
+func (s *Semaphore) removeWaiter(ready chan struct{}) {
+	for i, waiter := range s.waiters {
+		if waiter == ready {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return
+		}
+	}
+}
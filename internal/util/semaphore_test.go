@@ -0,0 +1,124 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSemaphore_SetWidth(t *testing.T) {
+	sem := NewSemaphore(2)
+	ctx := context.Background()
+
+	for range 2 {
+		if err := sem.Acquire(ctx); err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+	}
+
+	// Shrinking holds back new operations until the running ones finish
+	sem.SetWidth(1)
+	acquired := make(chan struct{})
+	go func() {
+		if err := sem.Acquire(ctx); err == nil {
+			close(acquired)
+		}
+	}()
+
+	sem.Release()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() admitted an operation above the width")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sem.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() not admitted after releasing")
+	}
+
+	// Widening admits waiters straight away
+	waiting := make(chan struct{})
+	go func() {
+		if err := sem.Acquire(ctx); err == nil {
+			close(waiting)
+		}
+	}()
+	sem.SetWidth(2)
+	select {
+	case <-waiting:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() not admitted after widening")
+	}
+}
+
+func TestNewAdaptiveSemaphore(t *testing.T) {
+	var mu sync.Mutex
+	width := 2
+	sem := NewAdaptiveSemaphore(func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return width
+	})
+	ctx := context.Background()
+
+	for range 2 {
+		if err := sem.Acquire(ctx); err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+	}
+
+	// The narrower width is picked up when a running operation ends
+	mu.Lock()
+	width = 1
+	mu.Unlock()
+	acquired := make(chan struct{})
+	go func() {
+		if err := sem.Acquire(ctx); err == nil {
+			close(acquired)
+		}
+	}()
+
+	sem.Release()
+	if got := sem.Width(); got != 1 {
+		t.Errorf("Width() = %d, want 1", got)
+	}
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() admitted an operation above the width")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sem.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() not admitted after releasing")
+	}
+}
+
+func TestSemaphore_Acquire_canceled(t *testing.T) {
+	sem := NewSemaphore(0)
+	if got := sem.Width(); got != 1 {
+		t.Errorf("Width() = %d, want 1", got)
+	}
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := sem.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() error = %v, want deadline exceeded", err)
+	}
+
+	// The canceled waiter must not hold on to a slot
+	sem.Release()
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+}
@@ -1,4 +1,4 @@
-// Package util provides utility functions for YAML marshaling.
+// Package util provides utility functions for YAML marshaling and diffing.
 package util
 
 import (